@@ -0,0 +1,296 @@
+package forge
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// BasicAuthValidator checks HTTP Basic credentials
+type BasicAuthValidator func(c *Context, username, password string) (bool, error)
+
+// BasicAuthConfig represents HTTP Basic authentication configuration
+type BasicAuthConfig struct {
+	Validator BasicAuthValidator
+	Realm     string
+	Skipper   func(*Context) bool
+}
+
+// NewBasicAuthConfig creates a Basic auth configuration
+func NewBasicAuthConfig(validator BasicAuthValidator) *BasicAuthConfig {
+	return &BasicAuthConfig{
+		Validator: validator,
+		Realm:     "Restricted",
+	}
+}
+
+// BasicAuth creates an HTTP Basic authentication middleware
+func BasicAuth(validator BasicAuthValidator) MiddlewareFunc {
+	return BasicAuthWithConfig(NewBasicAuthConfig(validator))
+}
+
+// BasicAuthWithConfig creates an HTTP Basic authentication middleware.
+// The username becomes the user ID returned by GetUserID.
+func BasicAuthWithConfig(config *BasicAuthConfig) MiddlewareFunc {
+	challenge := fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", config.Realm)
+
+	return func(c *Context) error {
+		if config.Skipper != nil && config.Skipper(c) {
+			return c.Next()
+		}
+
+		username, password, ok := c.Request.BasicAuth()
+		if ok {
+			valid, err := config.Validator(c, username, password)
+			if err != nil {
+				return err
+			}
+			if valid {
+				c.Set("user_id", username)
+				return c.Next()
+			}
+		}
+
+		c.Header("WWW-Authenticate", challenge)
+		return ErrUnauthorized
+	}
+}
+
+// BasicAuthUsers returns a validator for a fixed set of username/password
+// pairs. Comparisons take constant time and unknown users cost the same as
+// wrong passwords.
+func BasicAuthUsers(users map[string]string) BasicAuthValidator {
+	digests := make(map[string][32]byte, len(users))
+	for username, password := range users {
+		digests[username] = sha256.Sum256([]byte(password))
+	}
+	dummy := sha256.Sum256([]byte("forge-unknown-user"))
+
+	return func(c *Context, username, password string) (bool, error) {
+		expected, known := digests[username]
+		if !known {
+			expected = dummy
+		}
+		given := sha256.Sum256([]byte(password))
+		return subtle.ConstantTimeCompare(given[:], expected[:]) == 1 && known, nil
+	}
+}
+
+// KeyLookupFunc resolves an API key to a user ID ("" for unknown keys)
+type KeyLookupFunc func(c *Context, key string) (string, error)
+
+// KeyAuth creates an API key authentication middleware. The source uses
+// the JWT token lookup syntax: "header:X-API-Key", "query:api_key" or
+// "header:Authorization:ApiKey ". The resolved user ID is returned by GetUserID.
+func KeyAuth(lookup KeyLookupFunc, source string) MiddlewareFunc {
+	extractors := parseTokenLookup(source)
+
+	return func(c *Context) error {
+		key, err := extractToken(c, extractors)
+		if err != nil {
+			return ErrUnauthorized
+		}
+		userID, err := lookup(c, key)
+		if err != nil {
+			return err
+		}
+		if userID == "" {
+			return ErrUnauthorized
+		}
+		c.Set("user_id", userID)
+		return c.Next()
+	}
+}
+
+// KeyAuthKeys returns a lookup for a fixed map of API keys to user IDs.
+// Every key is compared in constant time.
+func KeyAuthKeys(keys map[string]string) KeyLookupFunc {
+	type entry struct {
+		digest [32]byte
+		userID string
+	}
+	entries := make([]entry, 0, len(keys))
+	for key, userID := range keys {
+		entries = append(entries, entry{sha256.Sum256([]byte(key)), userID})
+	}
+
+	return func(c *Context, key string) (string, error) {
+		given := sha256.Sum256([]byte(key))
+		userID := ""
+		for _, e := range entries {
+			if subtle.ConstantTimeCompare(given[:], e.digest[:]) == 1 {
+				userID = e.userID
+			}
+		}
+		return userID, nil
+	}
+}
+
+// SignatureKeyFunc returns the shared secret of a client key ID (nil if unknown)
+type SignatureKeyFunc func(keyID string) ([]byte, error)
+
+// SignatureAuthConfig represents HMAC request-signature authentication
+// configuration. Clients sign
+//
+//	METHOD \n request URI \n timestamp \n nonce \n hex(sha256(body))
+//
+// with HMAC-SHA256 and send the hex signature with the key ID, timestamp
+// and nonce headers (see SignRequest).
+type SignatureAuthConfig struct {
+	Keys            SignatureKeyFunc
+	MaxSkew         time.Duration // Accepted clock difference for timestamps
+	ReplayStore     Store         // Remembers nonces for 2*MaxSkew
+	MaxBodySize     int64
+	HeaderKeyID     string
+	HeaderTimestamp string
+	HeaderNonce     string
+	HeaderSignature string
+	Skipper         func(*Context) bool
+}
+
+// NewSignatureAuthConfig creates a signature auth configuration
+func NewSignatureAuthConfig(keys SignatureKeyFunc) *SignatureAuthConfig {
+	return &SignatureAuthConfig{
+		Keys:            keys,
+		MaxSkew:         5 * time.Minute,
+		ReplayStore:     NewMemoryStore(),
+		MaxBodySize:     10 << 20,
+		HeaderKeyID:     "X-Key-Id",
+		HeaderTimestamp: "X-Timestamp",
+		HeaderNonce:     "X-Nonce",
+		HeaderSignature: "X-Signature",
+	}
+}
+
+// Signature auth errors
+var (
+	ErrSignatureMissing  = NewHTTPError(http.StatusUnauthorized, "missing request signature")
+	ErrSignatureExpired  = NewHTTPError(http.StatusUnauthorized, "request timestamp outside allowed window")
+	ErrSignatureInvalid  = NewHTTPError(http.StatusUnauthorized, "invalid request signature")
+	ErrSignatureReplayed = NewHTTPError(http.StatusUnauthorized, "request already processed")
+)
+
+// errNonceSeen aborts the replay store update for a known nonce
+var errNonceSeen = errors.New("nonce seen")
+
+// SignatureAuth creates an HMAC request-signature authentication middleware.
+// The key ID becomes the user ID returned by GetUserID.
+func SignatureAuth(config *SignatureAuthConfig) MiddlewareFunc {
+	return func(c *Context) error {
+		if config.Skipper != nil && config.Skipper(c) {
+			return c.Next()
+		}
+
+		header := c.Request.Header
+		keyID := header.Get(config.HeaderKeyID)
+		timestamp := header.Get(config.HeaderTimestamp)
+		nonce := header.Get(config.HeaderNonce)
+		signature := header.Get(config.HeaderSignature)
+		if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+			return ErrSignatureMissing
+		}
+
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return ErrSignatureInvalid
+		}
+		if skew := time.Since(time.Unix(unix, 0)); skew > config.MaxSkew || skew < -config.MaxSkew {
+			return ErrSignatureExpired
+		}
+
+		secret, err := config.Keys(keyID)
+		if err != nil {
+			return err
+		}
+		given, err := hex.DecodeString(signature)
+		if secret == nil || err != nil {
+			return ErrSignatureInvalid
+		}
+
+		body, err := readBody(c.Request, config.MaxBodySize)
+		if err != nil {
+			return err
+		}
+		expected := requestSignature(secret, c.Request.Method, c.Request.URL.RequestURI(), timestamp, nonce, body)
+		if !hmac.Equal(given, expected) {
+			return ErrSignatureInvalid
+		}
+
+		// Only verified requests reach the replay store, so attackers
+		// cannot fill it with junk nonces
+		err = config.ReplayStore.Update(c.Request.Context(), "signature:"+keyID+":"+nonce, 2*config.MaxSkew, func(current []byte) ([]byte, error) {
+			if current != nil {
+				return nil, errNonceSeen
+			}
+			return []byte{1}, nil
+		})
+		if errors.Is(err, errNonceSeen) {
+			return ErrSignatureReplayed
+		}
+		if err != nil {
+			return err
+		}
+
+		c.Set("user_id", keyID)
+		return c.Next()
+	}
+}
+
+// SignRequest signs an outgoing request for SignatureAuth using the
+// default header names. The body is read and replaced.
+func SignRequest(req *http.Request, keyID string, secret []byte) error {
+	body, err := readBody(req, -1)
+	if err != nil {
+		return err
+	}
+	nonce, err := randomToken(16)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := requestSignature(secret, req.Method, req.URL.RequestURI(), timestamp, nonce, body)
+
+	req.Header.Set("X-Key-Id", keyID)
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set("X-Nonce", nonce)
+	req.Header.Set("X-Signature", hex.EncodeToString(signature))
+	return nil
+}
+
+// requestSignature computes the HMAC over the canonical request
+func requestSignature(secret []byte, method, uri, timestamp, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	h := hmac.New(sha256.New, secret)
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n%s", method, uri, timestamp, nonce, hex.EncodeToString(bodyHash[:]))
+	return h.Sum(nil)
+}
+
+// readBody reads the request body and replaces it so handlers can read it
+// again. A negative limit reads everything.
+func readBody(req *http.Request, limit int64) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	reader := io.Reader(req.Body)
+	if limit >= 0 {
+		reader = io.LimitReader(req.Body, limit+1)
+	}
+	body, err := io.ReadAll(reader)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if limit >= 0 && int64(len(body)) > limit {
+		return nil, NewHTTPError(http.StatusRequestEntityTooLarge)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,133 @@
+package forge
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBasicAuth(t *testing.T) {
+	app := New()
+	app.Use(BasicAuth(BasicAuthUsers(map[string]string{"alice": "s3cret"})))
+	app.GET("/", func(c *Context) error { return c.String(200, GetUserID(c)) })
+
+	tests := []struct {
+		user, pass string
+		code       int
+	}{
+		{"alice", "s3cret", 200},
+		{"alice", "wrong", 401},
+		{"bob", "s3cret", 401},
+		{"", "", 401},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.user != "" {
+			req.SetBasicAuth(tt.user, tt.pass)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s/%s: expected %d, got %d", tt.user, tt.pass, tt.code, w.Code)
+		}
+		if tt.code == 200 && w.Body.String() != "alice" {
+			t.Errorf("Expected user ID alice, got %q", w.Body.String())
+		}
+		if tt.code == 401 && w.Header().Get("WWW-Authenticate") != `Basic realm="Restricted", charset="UTF-8"` {
+			t.Errorf("Unexpected challenge %q", w.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestKeyAuth(t *testing.T) {
+	lookup := KeyAuthKeys(map[string]string{"key-123": "service-a"})
+	app := New()
+	app.Group("/h", KeyAuth(lookup, "header:X-API-Key")).GET("/", func(c *Context) error { return c.String(200, GetUserID(c)) })
+	app.Group("/q", KeyAuth(lookup, "query:api_key")).GET("/", func(c *Context) error { return c.String(200, GetUserID(c)) })
+
+	req := httptest.NewRequest("GET", "/h/", nil)
+	req.Header.Set("X-API-Key", "key-123")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Body.String() != "service-a" {
+		t.Errorf("Expected header key to authenticate, got %d %q", w.Code, w.Body.String())
+	}
+
+	for _, path := range []string{"/q/?api_key=key-123", "/q/?api_key=nope", "/q/"} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		expected := 401
+		if strings.HasSuffix(path, "key-123") {
+			expected = 200
+		}
+		if w.Code != expected {
+			t.Errorf("%s: expected %d, got %d", path, expected, w.Code)
+		}
+	}
+}
+
+func TestSignatureAuth(t *testing.T) {
+	secret := []byte("shared-secret")
+	config := NewSignatureAuthConfig(func(keyID string) ([]byte, error) {
+		if keyID == "billing" {
+			return secret, nil
+		}
+		return nil, nil
+	})
+	app := New()
+	app.Use(SignatureAuth(config))
+	app.POST("/hooks", func(c *Context) error {
+		body := make([]byte, 64)
+		n, _ := c.Request.Body.Read(body)
+		return c.String(200, GetUserID(c)+":"+string(body[:n]))
+	})
+
+	send := func(mutate func(r *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/hooks?x=1", strings.NewReader(`{"paid":true}`))
+		if err := SignRequest(req, "billing", secret); err != nil {
+			t.Fatal(err)
+		}
+		if mutate != nil {
+			mutate(req)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send(nil); w.Code != 200 || w.Body.String() != `billing:{"paid":true}` {
+		t.Fatalf("Expected signed request to pass, got %d %q", w.Code, w.Body.String())
+	}
+
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	cases := map[string]func(r *http.Request){
+		"tampered body":  func(r *http.Request) { r.Body = io.NopCloser(strings.NewReader(`{"paid":false}`)) },
+		"tampered query": func(r *http.Request) { r.URL.RawQuery = "x=2" },
+		"unknown key":    func(r *http.Request) { r.Header.Set("X-Key-Id", "other") },
+		"stale":          func(r *http.Request) { r.Header.Set("X-Timestamp", stale) },
+		"missing":        func(r *http.Request) { r.Header.Del("X-Signature") },
+	}
+	for name, mutate := range cases {
+		if w := send(mutate); w.Code != 401 {
+			t.Errorf("%s: expected 401, got %d", name, w.Code)
+		}
+	}
+
+	// Replaying an identical signed request is rejected
+	req := httptest.NewRequest("POST", "/hooks", strings.NewReader("once"))
+	SignRequest(req, "billing", secret)
+	headers := req.Header.Clone()
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	replay := httptest.NewRequest("POST", "/hooks", strings.NewReader("once"))
+	replay.Header = headers
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, replay)
+	if w.Code != 401 || !strings.Contains(w.Body.String(), "already processed") {
+		t.Errorf("Expected replay to be rejected, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,168 @@
+package forge
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policy decides whether the current request is allowed. Policies can read
+// the identity, claims and route params from the context and are combined
+// with AllOf, AnyOf and Not.
+type Policy func(c *Context) bool
+
+// AllOf allows a request when every policy allows it
+func AllOf(policies ...Policy) Policy {
+	return func(c *Context) bool {
+		for _, policy := range policies {
+			if !policy(c) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyOf allows a request when at least one policy allows it
+func AnyOf(policies ...Policy) Policy {
+	return func(c *Context) bool {
+		for _, policy := range policies {
+			if policy(c) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not inverts a policy
+func Not(policy Policy) Policy {
+	return func(c *Context) bool {
+		return !policy(c)
+	}
+}
+
+// HasRole allows users holding any of the roles
+func HasRole(roles ...string) Policy {
+	return func(c *Context) bool {
+		return containsAny(GetRoles(c), roles)
+	}
+}
+
+// HasScope allows tokens granted every one of the scopes
+func HasScope(scopes ...string) Policy {
+	return func(c *Context) bool {
+		granted := GetScopes(c)
+		for _, scope := range scopes {
+			if !containsAny(granted, []string{scope}) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// IsOwner allows the user whose ID equals the named route param,
+// e.g. IsOwner("id") for /users/:id
+func IsOwner(param string) Policy {
+	return func(c *Context) bool {
+		userID := GetUserID(c)
+		return userID != "" && userID == c.Params[param]
+	}
+}
+
+// Authorize creates a middleware enforcing a policy. Requests without an
+// identity fail with ErrUnauthorized and denied requests with ErrForbidden,
+// both handled by the central error handler.
+func Authorize(policy Policy) MiddlewareFunc {
+	return func(c *Context) error {
+		if GetUserID(c) == "" {
+			return ErrUnauthorized
+		}
+		if !policy(c) {
+			return ErrForbidden
+		}
+		return c.Next()
+	}
+}
+
+// RequireRoles creates a middleware allowing users holding any of the roles
+func RequireRoles(roles ...string) MiddlewareFunc {
+	return Authorize(HasRole(roles...))
+}
+
+// RequireScopes creates a middleware allowing tokens granted every scope.
+// Denied requests get an RFC 6750 insufficient_scope challenge.
+func RequireScopes(scopes ...string) MiddlewareFunc {
+	challenge := fmt.Sprintf("Bearer error=\"insufficient_scope\", scope=%q", strings.Join(scopes, " "))
+	policy := HasScope(scopes...)
+	return func(c *Context) error {
+		if GetUserID(c) == "" {
+			return ErrUnauthorized
+		}
+		if !policy(c) {
+			c.Header("WWW-Authenticate", challenge)
+			return ErrForbidden
+		}
+		return c.Next()
+	}
+}
+
+// GetRoles returns the roles of the current user, read from the "roles"
+// local set by authentication middleware or the "roles"/"role" JWT claims
+func GetRoles(c *Context) []string {
+	if roles, ok := c.Get("roles").([]string); ok {
+		return roles
+	}
+	if roles := stringList(GetClaim(c, "roles")); len(roles) > 0 {
+		return roles
+	}
+	return stringList(GetClaim(c, "role"))
+}
+
+// GetScopes returns the scopes granted to the current token, read from the
+// "scopes" local or the "scope" (space-delimited) and "scp" JWT claims
+func GetScopes(c *Context) []string {
+	if scopes, ok := c.Get("scopes").([]string); ok {
+		return scopes
+	}
+	if scope, ok := GetClaim(c, "scope").(string); ok {
+		return strings.Fields(scope)
+	}
+	if scp, ok := GetClaim(c, "scp").(string); ok {
+		return strings.Fields(scp)
+	}
+	return stringList(GetClaim(c, "scp"))
+}
+
+// stringList converts a claim holding a string or an array of strings
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func containsAny(values, wanted []string) bool {
+	for _, value := range values {
+		for _, w := range wanted {
+			if value == w {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package forge
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorizePolicies(t *testing.T) {
+	jwtConfig := NewJWTConfig("secret")
+	tokens := map[string]string{}
+	for name, claims := range map[string]map[string]interface{}{
+		"admin":  {"sub": "1", "roles": []string{"admin", "editor"}, "scope": "users:read users:write"},
+		"owner":  {"sub": "7", "role": "member", "scp": []string{"users:read"}},
+		"banned": {"sub": "9", "roles": []string{"admin", "banned"}},
+	} {
+		tokens[name], _ = jwtConfig.GenerateToken(claims)
+	}
+
+	app := New()
+	app.Use(JWTOptional(jwtConfig))
+	ownerOrAdmin := AnyOf(IsOwner("id"), AllOf(HasRole("admin"), Not(HasRole("banned"))))
+	app.Group("/profiles", Authorize(ownerOrAdmin)).GET("/:id", func(c *Context) error { return c.String(200, "profile") })
+	app.Group("/admin", RequireRoles("admin")).GET("/stats", func(c *Context) error { return c.String(200, "stats") })
+	app.Group("/api", RequireScopes("users:read")).GET("/users", func(c *Context) error { return c.String(200, "users") })
+	app.Group("/api", RequireScopes("users:write")).POST("/users", func(c *Context) error { return c.String(201, "created") })
+
+	tests := []struct {
+		method, path, token string
+		code                int
+	}{
+		{"GET", "/profiles/7", "", 401},
+		{"GET", "/profiles/7", "owner", 200},
+		{"GET", "/profiles/8", "owner", 403},
+		{"GET", "/profiles/8", "admin", 200},
+		{"GET", "/profiles/8", "banned", 403},
+		{"GET", "/admin/stats", "admin", 200},
+		{"GET", "/admin/stats", "owner", 403},
+		{"GET", "/api/users", "owner", 200},
+		{"POST", "/api/users", "owner", 403},
+		{"POST", "/api/users", "admin", 201},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tokens[tt.token])
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s %s as %q: expected %d, got %d", tt.method, tt.path, tt.token, tt.code, w.Code)
+		}
+		if tt.method == "POST" && tt.code == 403 && w.Header().Get("WWW-Authenticate") == "" {
+			t.Error("Expected insufficient_scope challenge")
+		}
+	}
+}
+
+func TestCentralErrorHandler(t *testing.T) {
+	app := New()
+	app.Use(RequireRoles("admin"))
+	app.GET("/", func(c *Context) error { return c.String(200, "OK") })
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 401 || w.Body.String() != "{\"error\":\"Unauthorized\"}\n" {
+		t.Errorf("Expected default JSON 401, got %d %q", w.Code, w.Body.String())
+	}
+
+	var handled error
+	app.SetErrorHandler(func(c *Context, err error) {
+		handled = err
+		c.String(err.(*HTTPError).Code, "custom")
+	})
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if handled != ErrUnauthorized || w.Body.String() != "custom" {
+		t.Errorf("Expected custom handler to run, got %v %q", handled, w.Body.String())
+	}
+}
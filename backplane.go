@@ -0,0 +1,394 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Backplane fans messages out across Forge instances. Every subscriber of
+// a channel, on any instance, receives what is published on it. Delivery
+// is at most once: messages published while a subscriber is disconnected
+// are lost.
+type Backplane interface {
+	// Publish sends payload to every subscriber of channel
+	Publish(ctx context.Context, channel string, payload []byte) error
+	// Subscribe calls handler for every payload published on channel until
+	// unsubscribe is called. Handlers must not block.
+	Subscribe(channel string, handler func(payload []byte)) (unsubscribe func(), err error)
+}
+
+// backplaneHandlers is a registry of subscription handlers per channel
+type backplaneHandlers struct {
+	nextID   uint64
+	channels map[string]map[uint64]func([]byte)
+}
+
+func newBackplaneHandlers() backplaneHandlers {
+	return backplaneHandlers{channels: make(map[string]map[uint64]func([]byte))}
+}
+
+// add registers a handler and reports whether it is the first of its channel
+func (h *backplaneHandlers) add(channel string, handler func([]byte)) (uint64, bool) {
+	h.nextID++
+	first := h.channels[channel] == nil
+	if first {
+		h.channels[channel] = make(map[uint64]func([]byte))
+	}
+	h.channels[channel][h.nextID] = handler
+	return h.nextID, first
+}
+
+// remove unregisters a handler and reports whether its channel is now empty
+func (h *backplaneHandlers) remove(channel string, id uint64) bool {
+	handlers, ok := h.channels[channel]
+	if !ok {
+		return false
+	}
+	delete(handlers, id)
+	if len(handlers) > 0 {
+		return false
+	}
+	delete(h.channels, channel)
+	return true
+}
+
+// get returns a snapshot of the handlers of a channel
+func (h *backplaneHandlers) get(channel string) []func([]byte) {
+	handlers := make([]func([]byte), 0, len(h.channels[channel]))
+	for _, handler := range h.channels[channel] {
+		handlers = append(handlers, handler)
+	}
+	return handlers
+}
+
+// backplaneLink connects a local fan-out (broadcaster or hub) to a
+// backplane channel. Messages carry the publishing instance, which already
+// delivered them locally and skips its own copy.
+type backplaneLink struct {
+	backplane   Backplane
+	channel     string
+	origin      string
+	unsubscribe func()
+}
+
+// backplaneEnvelope wraps a payload published by a link
+type backplaneEnvelope struct {
+	Origin  string `json:"origin"`
+	Payload []byte `json:"payload"`
+}
+
+// newBackplaneLink subscribes deliver to payloads published by other instances
+func newBackplaneLink(backplane Backplane, channel string, deliver func([]byte)) (*backplaneLink, error) {
+	origin, err := randomToken(12)
+	if err != nil {
+		return nil, err
+	}
+	link := &backplaneLink{backplane: backplane, channel: channel, origin: origin}
+
+	link.unsubscribe, err = backplane.Subscribe(channel, func(payload []byte) {
+		var envelope backplaneEnvelope
+		if json.Unmarshal(payload, &envelope) != nil || envelope.Origin == origin {
+			return
+		}
+		deliver(envelope.Payload)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// publish sends a payload to the other instances. Fan-out calls have no
+// error return, so failures are logged.
+func (l *backplaneLink) publish(payload []byte) {
+	data, err := json.Marshal(backplaneEnvelope{Origin: l.origin, Payload: payload})
+	if err == nil {
+		err = l.backplane.Publish(context.Background(), l.channel, data)
+	}
+	if err != nil {
+		log.Printf("backplane: publish on %s: %v", l.channel, err)
+	}
+}
+
+// MemoryBackplane is an in-process backplane for single-instance apps and tests
+type MemoryBackplane struct {
+	mu       sync.RWMutex
+	handlers backplaneHandlers
+}
+
+// NewMemoryBackplane creates an in-process backplane
+func NewMemoryBackplane() *MemoryBackplane {
+	return &MemoryBackplane{handlers: newBackplaneHandlers()}
+}
+
+// Publish calls every handler of channel synchronously
+func (b *MemoryBackplane) Publish(ctx context.Context, channel string, payload []byte) error {
+	b.mu.RLock()
+	handlers := b.handlers.get(channel)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(payload)
+	}
+	return nil
+}
+
+// Subscribe registers a handler for channel
+func (b *MemoryBackplane) Subscribe(channel string, handler func([]byte)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id, _ := b.handlers.add(channel, handler)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.handlers.remove(channel, id)
+		})
+	}, nil
+}
+
+// RedisBackplane is a backplane on Redis-protocol pub/sub. It publishes
+// through the client pool and keeps one dedicated subscriber connection,
+// reconnecting and resubscribing when it drops.
+type RedisBackplane struct {
+	client    *RedisClient
+	prefix    string
+	keepalive time.Duration
+
+	mu        sync.Mutex
+	handlers  backplaneHandlers
+	conn      *redisConn // nil while disconnected
+	confirmed map[string]bool
+	waiters   map[string][]chan struct{}
+	closed    bool
+	done      chan struct{}
+}
+
+// NewRedisBackplane creates a Redis backplane; channel names are prefixed with prefix
+func NewRedisBackplane(client *RedisClient, prefix string) *RedisBackplane {
+	b := &RedisBackplane{
+		client:    client,
+		prefix:    prefix,
+		keepalive: 30 * time.Second,
+		handlers:  newBackplaneHandlers(),
+		confirmed: make(map[string]bool),
+		waiters:   make(map[string][]chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Publish sends payload with PUBLISH
+func (b *RedisBackplane) Publish(ctx context.Context, channel string, payload []byte) error {
+	_, err := b.client.Do(ctx, "PUBLISH", b.prefix+channel, string(payload))
+	return err
+}
+
+// Subscribe registers a handler and returns once the server has confirmed
+// the subscription, so messages published afterwards are delivered
+func (b *RedisBackplane) Subscribe(channel string, handler func([]byte)) (func(), error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, ErrRedisClosed
+	}
+	id, first := b.handlers.add(channel, handler)
+	if first && b.conn != nil {
+		b.sendLocked("SUBSCRIBE", b.prefix+channel)
+	}
+	var confirmed chan struct{}
+	if !b.confirmed[channel] {
+		confirmed = make(chan struct{})
+		b.waiters[channel] = append(b.waiters[channel], confirmed)
+	}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if b.handlers.remove(channel, id) {
+				delete(b.confirmed, channel)
+				if b.conn != nil {
+					b.sendLocked("UNSUBSCRIBE", b.prefix+channel)
+				}
+			}
+		})
+	}
+
+	if confirmed != nil {
+		wait := b.client.config.DialTimeout + b.client.config.IOTimeout
+		if wait <= 0 {
+			wait = 10 * time.Second
+		}
+		timeout := time.NewTimer(wait)
+		defer timeout.Stop()
+		select {
+		case <-confirmed:
+		case <-timeout.C:
+			unsubscribe()
+			return nil, errors.New("redis: subscription to " + channel + " not confirmed")
+		case <-b.done:
+			return nil, ErrRedisClosed
+		}
+	}
+	return unsubscribe, nil
+}
+
+// Close stops the subscriber connection
+func (b *RedisBackplane) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	close(b.done)
+	if b.conn != nil {
+		b.conn.Close()
+	}
+	return nil
+}
+
+// run keeps the subscriber connection alive, backing off between attempts
+func (b *RedisBackplane) run() {
+	backoff := 100 * time.Millisecond
+	for {
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if b.client.config.DialTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, b.client.config.DialTimeout)
+		}
+		conn, err := b.client.dial(ctx)
+		cancel()
+
+		if err == nil {
+			b.mu.Lock()
+			if b.closed {
+				b.mu.Unlock()
+				conn.Close()
+				return
+			}
+			b.conn = conn
+			channels := make([]string, 0, len(b.handlers.channels)+1)
+			channels = append(channels, "SUBSCRIBE")
+			for channel := range b.handlers.channels {
+				channels = append(channels, b.prefix+channel)
+			}
+			if len(channels) > 1 {
+				b.sendLocked(channels...)
+			}
+			b.mu.Unlock()
+
+			backoff = 100 * time.Millisecond
+			b.readLoop(conn)
+
+			b.mu.Lock()
+			b.conn = nil
+			b.confirmed = make(map[string]bool)
+			b.mu.Unlock()
+			conn.Close()
+		}
+
+		select {
+		case <-b.done:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 5*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// readLoop dispatches pushed messages until the connection fails. A quiet
+// connection is pinged, and dropped when the ping goes unanswered.
+func (b *RedisBackplane) readLoop(conn *redisConn) {
+	pinged := false
+	for {
+		conn.conn.SetReadDeadline(time.Now().Add(b.keepalive))
+		if _, err := conn.r.Peek(1); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && !pinged {
+				b.mu.Lock()
+				b.sendLocked("PING")
+				b.mu.Unlock()
+				pinged = true
+				continue
+			}
+			return
+		}
+		pinged = false
+
+		conn.conn.SetReadDeadline(ioDeadline(b.client.config.IOTimeout))
+		reply, err := conn.receive()
+		if err != nil {
+			return
+		}
+		b.dispatch(reply)
+	}
+}
+
+// dispatch handles one pushed reply
+func (b *RedisBackplane) dispatch(reply interface{}) {
+	items, ok := reply.([]interface{})
+	if !ok || len(items) < 2 {
+		return
+	}
+	kind, _ := items[0].([]byte)
+	name, _ := items[1].([]byte)
+	if len(name) < len(b.prefix) {
+		return
+	}
+	channel := string(name[len(b.prefix):])
+
+	switch string(kind) {
+	case "subscribe":
+		b.mu.Lock()
+		b.confirmed[channel] = true
+		for _, waiter := range b.waiters[channel] {
+			close(waiter)
+		}
+		delete(b.waiters, channel)
+		b.mu.Unlock()
+	case "message":
+		if len(items) < 3 {
+			return
+		}
+		payload, _ := items[2].([]byte)
+		b.mu.Lock()
+		handlers := b.handlers.get(channel)
+		b.mu.Unlock()
+		for _, handler := range handlers {
+			handler(payload)
+		}
+	}
+}
+
+// sendLocked writes a command on the subscriber connection; the caller
+// holds b.mu. Failures surface in readLoop, which reconnects.
+func (b *RedisBackplane) sendLocked(args ...string) {
+	if b.conn == nil {
+		return
+	}
+	b.conn.conn.SetWriteDeadline(ioDeadline(b.client.config.IOTimeout))
+	if err := b.conn.send(args...); err != nil {
+		b.conn.Close()
+	}
+}
+
+// ioDeadline returns the deadline for an I/O timeout (none when zero)
+func ioDeadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
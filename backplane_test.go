@@ -0,0 +1,150 @@
+package forge
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryBackplane(t *testing.T) {
+	backplane := NewMemoryBackplane()
+	received := make(chan string, 4)
+	unsubscribe, _ := backplane.Subscribe("news", func(payload []byte) { received <- string(payload) })
+	backplane.Subscribe("other", func(payload []byte) { received <- "other:" + string(payload) })
+
+	backplane.Publish(context.Background(), "news", []byte("hello"))
+	if got := <-received; got != "hello" {
+		t.Errorf("expected hello, got %q", got)
+	}
+
+	unsubscribe()
+	backplane.Publish(context.Background(), "news", []byte("ignored"))
+	select {
+	case got := <-received:
+		t.Errorf("unexpected message after unsubscribe: %q", got)
+	default:
+	}
+}
+
+func TestRedisBackplaneReconnects(t *testing.T) {
+	fr := newFakeRedis(t)
+	client := NewRedisClient(NewRedisConfig(fr.Addr()))
+	defer client.Close()
+	backplane := NewRedisBackplane(client, "forge:")
+	defer backplane.Close()
+
+	received := make(chan string, 16)
+	if _, err := backplane.Subscribe("events", func(payload []byte) { received <- string(payload) }); err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	if err := backplane.Publish(context.Background(), "events", []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; got != "first" {
+		t.Errorf("expected first, got %q", got)
+	}
+
+	// Subscriptions are restored after the connection drops
+	fr.dropSubscribers()
+	deadline := time.After(3 * time.Second)
+	for {
+		backplane.Publish(context.Background(), "events", []byte("again"))
+		select {
+		case got := <-received:
+			if got != "again" {
+				t.Fatalf("unexpected message %q", got)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("expected subscription to be restored")
+		}
+	}
+}
+
+// backplaneInstance is one Forge replica with a hub and a broadcaster
+// sharing a Redis backplane
+func backplaneInstance(t *testing.T, redisAddr string) (*httptest.Server, chan struct{}) {
+	client := NewRedisClient(NewRedisConfig(redisAddr))
+	backplane := NewRedisBackplane(client, "forge:")
+	t.Cleanup(func() { backplane.Close(); client.Close() })
+
+	hub := NewWebSocketHub()
+	broadcaster := WebSocketBroadcast()
+	if err := hub.UseBackplane(backplane, "hub"); err != nil {
+		t.Fatal(err)
+	}
+	if err := broadcaster.UseBackplane(backplane, "broadcast"); err != nil {
+		t.Fatal(err)
+	}
+	joined := make(chan struct{}, 4)
+
+	app := New()
+	app.Use(func(c *Context) error {
+		c.Set("user_id", c.Request.URL.Query().Get("user"))
+		return c.Next()
+	})
+	app.WebSocket("/ws", func(conn *WebSocketConnection) {
+		hub.Join(conn, "general")
+		broadcaster.AddConnection(conn)
+		joined <- struct{}{}
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			switch string(data) {
+			case "broadcast":
+				broadcaster.Broadcast("to everyone")
+			case "dm":
+				hub.SendToUser("bob", TextMessage, []byte("direct"))
+			default:
+				hub.SendToRoom("general", TextMessage, data, conn)
+			}
+		}
+	})
+	server := httptest.NewServer(app)
+	t.Cleanup(server.Close)
+	return server, joined
+}
+
+func TestRedisBackplaneAcrossInstances(t *testing.T) {
+	fr := newFakeRedis(t)
+	serverA, joinedA := backplaneInstance(t, fr.Addr())
+	serverB, joinedB := backplaneInstance(t, fr.Addr())
+
+	alice := dialWebSocket(t, serverA, "/ws?user=alice", nil)
+	<-joinedA
+	carol := dialWebSocket(t, serverA, "/ws?user=carol", nil)
+	<-joinedA
+	bob := dialWebSocket(t, serverB, "/ws?user=bob", nil)
+	<-joinedB
+
+	// Room messages reach both instances but skip the sender
+	alice.writeFrame(0x80|TextMessage, []byte("hello room"))
+	for _, client := range []*wsTestClient{carol, bob} {
+		if _, payload := client.readFrame(); string(payload) != "hello room" {
+			t.Errorf("expected room message, got %q", payload)
+		}
+	}
+
+	// Users are reachable from any instance
+	carol.writeFrame(0x80|TextMessage, []byte("dm"))
+	if _, payload := bob.readFrame(); string(payload) != "direct" {
+		t.Errorf("expected direct message, got %q", payload)
+	}
+
+	// Broadcasts reach every connection exactly once
+	bob.writeFrame(0x80|TextMessage, []byte("broadcast"))
+	for _, client := range []*wsTestClient{alice, carol, bob} {
+		if _, payload := client.readFrame(); string(payload) != "to everyone" {
+			t.Errorf("expected broadcast, got %q", payload)
+		}
+	}
+	// Alice got nothing twice: the next frame is the one sent now
+	bob.writeFrame(0x80|TextMessage, []byte("last"))
+	if _, payload := alice.readFrame(); string(payload) != "last" {
+		t.Errorf("expected no duplicate delivery, got %q", payload)
+	}
+}
@@ -0,0 +1,163 @@
+package forge
+
+import (
+	"net/http"
+	"time"
+)
+
+// CookiePolicy controls the attributes applied to every cookie written
+// through the Context. Defaults fill in attributes a cookie leaves unset;
+// Force fields override whatever the handler chose.
+type CookiePolicy struct {
+	Path          string        // Default path when a cookie has none
+	Domain        string        // Forced domain for every cookie (empty leaves it alone)
+	SameSite      http.SameSite // Default SameSite when a cookie has none
+	ForceSameSite bool          // Overrides every cookie's SameSite with SameSite
+	SecureOnHTTPS bool          // Marks cookies Secure when the request arrived over HTTPS
+	ForceSecure   bool          // Marks every cookie Secure
+	ForceHTTPOnly bool          // Marks every cookie HttpOnly
+}
+
+// DefaultCookiePolicy returns the policy used when none is configured
+func DefaultCookiePolicy() CookiePolicy {
+	return CookiePolicy{
+		Path:          "/",
+		SameSite:      http.SameSiteLaxMode,
+		SecureOnHTTPS: true,
+	}
+}
+
+// apply enforces the policy on a cookie
+func (p CookiePolicy) apply(c *Context, cookie *http.Cookie) {
+	if cookie.Path == "" {
+		cookie.Path = p.Path
+	}
+	if p.Domain != "" {
+		cookie.Domain = p.Domain
+	}
+	if p.ForceSameSite || cookie.SameSite == 0 {
+		cookie.SameSite = p.SameSite
+	}
+	if p.ForceSecure || (p.SecureOnHTTPS && c.Scheme() == "https") {
+		cookie.Secure = true
+	}
+	if p.ForceHTTPOnly {
+		cookie.HttpOnly = true
+	}
+	// Browsers reject SameSite=None without Secure
+	if cookie.SameSite == http.SameSiteNoneMode {
+		cookie.Secure = true
+	}
+}
+
+// SetCookiePolicy sets the policy applied to cookies written through the Context
+func (f *Forge) SetCookiePolicy(policy CookiePolicy) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cookiePolicy = policy
+}
+
+// SetCookieKeys sets the keys used for signed and encrypted cookies.
+// The first key protects new cookies; the others are still accepted,
+// so keys can be rotated by prepending a new one.
+func (f *Forge) SetCookieKeys(keys ...[]byte) error {
+	ck, err := newCookieKeys(keys...)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cookieKeys = ck
+	return nil
+}
+
+// cookieSettings returns the app's cookie policy and keys
+func (c *Context) cookieSettings() (CookiePolicy, *cookieKeys) {
+	if c.forge == nil {
+		return DefaultCookiePolicy(), nil
+	}
+	c.forge.mu.RLock()
+	defer c.forge.mu.RUnlock()
+	return c.forge.cookiePolicy, c.forge.cookieKeys
+}
+
+// SetCookie writes a cookie after applying the app's cookie policy
+func (c *Context) SetCookie(cookie *http.Cookie) {
+	policy, _ := c.cookieSettings()
+	policy.apply(c, cookie)
+	http.SetCookie(c.Response, cookie)
+}
+
+// GetCookie returns the named request cookie
+func (c *Context) GetCookie(name string) (*http.Cookie, error) {
+	return c.Request.Cookie(name)
+}
+
+// SetSignedCookie writes a cookie whose value is authenticated with HMAC.
+// The value stays readable by the client but cannot be modified.
+func (c *Context) SetSignedCookie(cookie *http.Cookie) error {
+	_, keys := c.cookieSettings()
+	if keys == nil {
+		return ErrNoCookieKeys
+	}
+
+	signed := *cookie
+	signed.Value = keys.sign(cookie.Name, cookie.Value, cookieExpiry(cookie))
+	c.SetCookie(&signed)
+	return nil
+}
+
+// GetSignedCookie returns the verified value of a signed cookie
+func (c *Context) GetSignedCookie(name string) (string, error) {
+	_, keys := c.cookieSettings()
+	if keys == nil {
+		return "", ErrNoCookieKeys
+	}
+
+	cookie, err := c.Request.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return keys.verify(name, cookie.Value, time.Now())
+}
+
+// SetEncryptedCookie writes a cookie whose value is encrypted and
+// authenticated with AES-GCM, hiding it from the client
+func (c *Context) SetEncryptedCookie(cookie *http.Cookie) error {
+	_, keys := c.cookieSettings()
+	if keys == nil {
+		return ErrNoCookieKeys
+	}
+
+	value, err := keys.encrypt(cookie.Name, cookie.Value, cookieExpiry(cookie))
+	if err != nil {
+		return err
+	}
+	encrypted := *cookie
+	encrypted.Value = value
+	c.SetCookie(&encrypted)
+	return nil
+}
+
+// GetEncryptedCookie returns the decrypted value of an encrypted cookie
+func (c *Context) GetEncryptedCookie(name string) (string, error) {
+	_, keys := c.cookieSettings()
+	if keys == nil {
+		return "", ErrNoCookieKeys
+	}
+
+	cookie, err := c.Request.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return keys.decrypt(name, cookie.Value, time.Now())
+}
+
+// cookieExpiry returns when a cookie stops being valid (zero for session cookies)
+func cookieExpiry(cookie *http.Cookie) time.Time {
+	if cookie.MaxAge > 0 {
+		return time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+	}
+	return cookie.Expires
+}
@@ -0,0 +1,161 @@
+package forge
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCookieApp(t *testing.T, keys ...[]byte) *Forge {
+	t.Helper()
+	app := New()
+	if err := app.SetCookieKeys(keys...); err != nil {
+		t.Fatal(err)
+	}
+	app.GET("/set", func(c *Context) error {
+		if err := c.SetSignedCookie(&http.Cookie{Name: "signed", Value: "alice", MaxAge: 60}); err != nil {
+			return err
+		}
+		if err := c.SetEncryptedCookie(&http.Cookie{Name: "secret", Value: "token-123", HttpOnly: true}); err != nil {
+			return err
+		}
+		return c.String(200, "OK")
+	})
+	app.GET("/get", func(c *Context) error {
+		signed, err := c.GetSignedCookie("signed")
+		if err != nil {
+			return c.String(400, err.Error())
+		}
+		secret, err := c.GetEncryptedCookie("secret")
+		if err != nil {
+			return c.String(400, err.Error())
+		}
+		return c.String(200, signed+":"+secret)
+	})
+	return app
+}
+
+func responseCookies(app *Forge, req *http.Request) ([]*http.Cookie, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	return w.Result().Cookies(), w
+}
+
+func TestSignedAndEncryptedCookies(t *testing.T) {
+	app := newCookieApp(t, []byte("key-1"))
+	cookies, _ := responseCookies(app, httptest.NewRequest("GET", "/set", nil))
+	if len(cookies) != 2 {
+		t.Fatalf("Expected 2 cookies, got %d", len(cookies))
+	}
+	if strings.Contains(cookies[1].Value, "token-123") {
+		t.Error("Encrypted cookie leaks its value")
+	}
+
+	req := httptest.NewRequest("GET", "/get", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	_, w := responseCookies(app, req)
+	if w.Body.String() != "alice:token-123" {
+		t.Errorf("Expected round trip, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Tampering with the signed value is detected
+	tampered := httptest.NewRequest("GET", "/get", nil)
+	tampered.AddCookie(&http.Cookie{Name: "signed", Value: "Ym9i" + cookies[0].Value[7:]})
+	tampered.AddCookie(cookies[1])
+	_, w = responseCookies(app, tampered)
+	if w.Code != 400 || w.Body.String() != ErrInvalidCookie.Error() {
+		t.Errorf("Expected tampered cookie to be rejected, got %d %q", w.Code, w.Body.String())
+	}
+
+	// A value cannot be replayed under a different cookie name
+	swapped := httptest.NewRequest("GET", "/get", nil)
+	swapped.AddCookie(cookies[0])
+	swapped.AddCookie(&http.Cookie{Name: "secret", Value: cookies[0].Value})
+	_, w = responseCookies(app, swapped)
+	if w.Code != 400 {
+		t.Errorf("Expected swapped cookie to be rejected, got %d", w.Code)
+	}
+}
+
+func TestCookieKeyRotation(t *testing.T) {
+	old := newCookieApp(t, []byte("old-key"))
+	cookies, _ := responseCookies(old, httptest.NewRequest("GET", "/set", nil))
+
+	rotated := newCookieApp(t, []byte("new-key"), []byte("old-key"))
+	req := httptest.NewRequest("GET", "/get", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	if _, w := responseCookies(rotated, req); w.Body.String() != "alice:token-123" {
+		t.Errorf("Expected old cookies to stay valid after rotation, got %q", w.Body.String())
+	}
+
+	retired := newCookieApp(t, []byte("new-key"))
+	if _, w := responseCookies(retired, req); w.Code != 400 {
+		t.Errorf("Expected cookies from a retired key to be rejected, got %d", w.Code)
+	}
+}
+
+func TestCookieExpiryIsEnforced(t *testing.T) {
+	keys, _ := newCookieKeys([]byte("key"))
+	signed := keys.sign("name", "value", time.Now().Add(-time.Hour))
+	if _, err := keys.verify("name", signed, time.Now()); !errors.Is(err, ErrCookieExpired) {
+		t.Errorf("Expected ErrCookieExpired, got %v", err)
+	}
+}
+
+func TestCookiePolicy(t *testing.T) {
+	app := New()
+	app.SetTrustedProxies("192.0.2.1")
+	app.SetCookiePolicy(CookiePolicy{
+		Path:          "/",
+		Domain:        "example.com",
+		SameSite:      http.SameSiteStrictMode,
+		ForceSameSite: true,
+		SecureOnHTTPS: true,
+		ForceHTTPOnly: true,
+	})
+	app.GET("/", func(c *Context) error {
+		c.SetCookie(&http.Cookie{Name: "pref", Value: "dark", SameSite: http.SameSiteLaxMode})
+		return c.String(200, "OK")
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	cookies, _ := responseCookies(app, req)
+	cookie := cookies[0]
+	if cookie.Domain != "example.com" || cookie.Path != "/" {
+		t.Errorf("Expected forced domain and default path, got %q %q", cookie.Domain, cookie.Path)
+	}
+	if cookie.SameSite != http.SameSiteStrictMode || !cookie.HttpOnly || !cookie.Secure {
+		t.Errorf("Expected forced attributes, got %+v", cookie)
+	}
+
+	// Without keys the protected helpers fail loudly
+	c := &Context{Request: httptest.NewRequest("GET", "/", nil), Response: httptest.NewRecorder(), forge: app}
+	if err := c.SetSignedCookie(&http.Cookie{Name: "x", Value: "y"}); !errors.Is(err, ErrNoCookieKeys) {
+		t.Errorf("Expected ErrNoCookieKeys, got %v", err)
+	}
+}
+
+func TestCookieDefaults(t *testing.T) {
+	app := New()
+	app.GET("/", func(c *Context) error {
+		c.Cookie("theme", "dark", 3600)
+		return c.String(200, "OK")
+	})
+
+	cookies, _ := responseCookies(app, httptest.NewRequest("GET", "/", nil))
+	cookie := cookies[0]
+	if cookie.Path != "/" || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("Expected secure defaults, got %+v", cookie)
+	}
+	if cookie.Secure {
+		t.Error("Expected Secure to be left off for plain HTTP requests")
+	}
+}
@@ -0,0 +1,144 @@
+package forge
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig represents CORS configuration
+type CORSConfig struct {
+	AllowOrigins     []string                 // Exact origins, wildcard subdomains ("https://*.example.com") or "*"
+	AllowOriginFunc  func(origin string) bool // Custom origin check, consulted after AllowOrigins
+	AllowMethods     []string                 // Methods allowed in preflight responses
+	AllowHeaders     []string                 // Allowed request headers (empty reflects the requested ones)
+	ExposeHeaders    []string                 // Response headers readable by the browser
+	AllowCredentials bool                     // Allow cookies and credentials (never sent with "*")
+	MaxAge           int                      // Preflight cache duration in seconds (0 omits the header)
+}
+
+// NewCORSConfig creates a new CORS configuration for the given origins
+func NewCORSConfig(origins ...string) *CORSConfig {
+	return &CORSConfig{
+		AllowOrigins: origins,
+		AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
+		AllowHeaders: []string{"Content-Type", "Authorization"},
+	}
+}
+
+// CORS allows requests from any origin
+func CORS() MiddlewareFunc {
+	return CORSWithConfig(NewCORSConfig("*"))
+}
+
+// CORSWithConfig creates a CORS middleware. Preflight requests (OPTIONS
+// with Access-Control-Request-Method) are answered directly; every other
+// request, including plain OPTIONS, continues down the chain.
+func CORSWithConfig(config *CORSConfig) MiddlewareFunc {
+	allowAll := false
+	exact := make(map[string]bool)
+	wildcards := make([][2]string, 0)
+	for _, origin := range config.AllowOrigins {
+		switch {
+		case origin == "*":
+			allowAll = true
+		case strings.Contains(origin, "*"):
+			prefix, suffix, _ := strings.Cut(strings.ToLower(origin), "*")
+			wildcards = append(wildcards, [2]string{prefix, suffix})
+		default:
+			exact[strings.ToLower(origin)] = true
+		}
+	}
+
+	allowMethods := strings.Join(config.AllowMethods, ", ")
+	allowHeaders := strings.Join(config.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(config.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(config.MaxAge)
+
+	originAllowed := func(origin string) bool {
+		lower := strings.ToLower(origin)
+		if exact[lower] {
+			return true
+		}
+		for _, wildcard := range wildcards {
+			if matchWildcardOrigin(lower, wildcard[0], wildcard[1]) {
+				return true
+			}
+		}
+		return config.AllowOriginFunc != nil && config.AllowOriginFunc(origin)
+	}
+
+	return func(c *Context) error {
+		header := c.Response.Header()
+		origin := c.Request.Header.Get("Origin")
+		preflight := c.Request.Method == "OPTIONS" && origin != "" &&
+			c.Request.Header.Get("Access-Control-Request-Method") != ""
+
+		// "*" is always answered literally, so credentials are never allowed
+		// for every origin, and a static response does not vary by origin
+		allowOrigin := ""
+		switch {
+		case allowAll:
+			allowOrigin = "*"
+		case origin == "":
+		case originAllowed(origin):
+			allowOrigin = origin
+		}
+
+		if !allowAll {
+			header.Add("Vary", "Origin")
+		}
+
+		if !preflight {
+			if allowOrigin != "" {
+				header.Set("Access-Control-Allow-Origin", allowOrigin)
+				if config.AllowCredentials && allowOrigin != "*" {
+					header.Set("Access-Control-Allow-Credentials", "true")
+				}
+				if exposeHeaders != "" {
+					header.Set("Access-Control-Expose-Headers", exposeHeaders)
+				}
+			}
+			return c.Next()
+		}
+
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+
+		if allowOrigin == "" {
+			// Disallowed origin: answer without CORS headers so the browser blocks it
+			c.Response.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+
+		header.Set("Access-Control-Allow-Origin", allowOrigin)
+		header.Set("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+		} else if requested := c.Request.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+		if config.AllowCredentials && allowOrigin != "*" {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if config.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+
+		c.Response.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+// matchWildcardOrigin matches origins such as "https://api.example.com"
+// against a pattern split around "*" ("https://", ".example.com")
+func matchWildcardOrigin(origin, prefix, suffix string) bool {
+	if len(origin) <= len(prefix)+len(suffix) {
+		return false
+	}
+	if !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+	middle := origin[len(prefix) : len(origin)-len(suffix)]
+	return !strings.ContainsAny(middle, "/:@")
+}
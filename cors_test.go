@@ -0,0 +1,110 @@
+package forge
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSWithConfigOrigins(t *testing.T) {
+	config := NewCORSConfig("https://app.example.com", "https://*.example.org")
+	config.AllowCredentials = true
+	config.ExposeHeaders = []string{"X-Request-ID"}
+
+	app := New()
+	app.Use(CORSWithConfig(config))
+	app.GET("/data", func(c *Context) error { return c.String(200, "OK") })
+
+	tests := []struct {
+		origin string
+		want   string
+	}{
+		{"https://app.example.com", "https://app.example.com"},
+		{"https://api.example.org", "https://api.example.org"},
+		{"https://example.org", ""},
+		{"https://evil.com", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/data", nil)
+		req.Header.Set("Origin", tt.origin)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("%s: expected allow origin %q, got %q", tt.origin, tt.want, got)
+		}
+		if tt.want != "" && w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Errorf("%s: expected credentials to be allowed", tt.origin)
+		}
+		if w.Header().Get("Vary") != "Origin" {
+			t.Errorf("%s: expected Vary: Origin, got %q", tt.origin, w.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	config := NewCORSConfig("https://app.example.com")
+	config.MaxAge = 600
+
+	app := New()
+	api := app.Group("/api", CORSWithConfig(config))
+	api.POST("/items", func(c *Context) error { return c.String(201, "Created") })
+	app.GET("/public", func(c *Context) error { return c.String(200, "OK") })
+
+	req := httptest.NewRequest("OPTIONS", "/api/items", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != 204 {
+		t.Errorf("Expected preflight status 204, got %d", w.Code)
+	}
+	if w.Header().Get("Access-Control-Allow-Methods") == "" || w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("Missing preflight headers: %v", w.Header())
+	}
+
+	// A plain OPTIONS request is not a preflight
+	req = httptest.NewRequest("OPTIONS", "/api/items", nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Error("Non-preflight OPTIONS should not get preflight headers")
+	}
+	if w.Header().Get("Allow") != "OPTIONS, POST" {
+		t.Errorf("Expected Allow header, got %q", w.Header().Get("Allow"))
+	}
+
+	// Routes outside the group are unaffected by the group policy
+	req = httptest.NewRequest("GET", "/public", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Group CORS policy leaked to other routes")
+	}
+}
+
+func TestCORSWildcardNeverAllowsCredentials(t *testing.T) {
+	config := NewCORSConfig("*")
+	config.AllowCredentials = true
+
+	app := New()
+	app.Use(CORSWithConfig(config))
+	app.GET("/data", func(c *Context) error { return c.String(200, "OK") })
+
+	for _, method := range []string{"GET", "OPTIONS"} {
+		req := httptest.NewRequest(method, "/data", nil)
+		req.Header.Set("Origin", "https://evil.com")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("%s: expected literal *, got %q", method, got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+			t.Errorf("%s: expected no credentials header, got %q", method, got)
+		}
+	}
+}
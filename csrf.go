@@ -0,0 +1,260 @@
+package forge
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CSRFMode selects how CSRF tokens are stored and verified
+type CSRFMode string
+
+// Supported CSRF modes
+const (
+	// CSRFDoubleSubmit stores a signed token in a cookie that the client
+	// must echo back in a header or form field
+	CSRFDoubleSubmit CSRFMode = "double_submit"
+	// CSRFSynchronizer stores the token server-side, keyed by an opaque
+	// HttpOnly cookie, and only the token itself is sent to forms
+	CSRFSynchronizer CSRFMode = "synchronizer"
+)
+
+// Errors returned by the CSRF middleware
+var (
+	ErrCSRFTokenMissing = errors.New("missing CSRF token")
+	ErrCSRFTokenInvalid = errors.New("invalid CSRF token")
+)
+
+// CSRFConfig represents CSRF protection configuration
+type CSRFConfig struct {
+	Mode           CSRFMode
+	Secret         string        // HMAC key used to sign double-submit tokens
+	Store          Store         // Synchronizer token storage (defaults to a MemoryStore)
+	TTL            time.Duration // Token lifetime
+	HeaderName     string        // Request header carrying the token
+	FieldName      string        // Form or multipart field carrying the token
+	CookieName     string
+	CookiePath     string
+	CookieDomain   string
+	CookieSecure   bool
+	CookieSameSite http.SameSite
+	ExemptRoutes   []string // Route patterns ("/webhooks/:id") or method and pattern ("POST /hooks")
+	MaxMemory      int64    // Memory limit when parsing multipart forms
+	Skipper        func(*Context) bool
+	ErrorHandler   func(*Context, error) error
+}
+
+// NewCSRFConfig creates a double-submit CSRF configuration
+func NewCSRFConfig(secret string) *CSRFConfig {
+	return &CSRFConfig{
+		Mode:           CSRFDoubleSubmit,
+		Secret:         secret,
+		TTL:            12 * time.Hour,
+		HeaderName:     "X-CSRF-Token",
+		FieldName:      "_csrf",
+		CookieName:     "_csrf",
+		CookiePath:     "/",
+		CookieSecure:   true,
+		CookieSameSite: http.SameSiteLaxMode,
+		MaxMemory:      32 << 20,
+	}
+}
+
+// CSRF creates a CSRF protection middleware. Safe methods pass through
+// and receive a token; unsafe methods must submit it through the header,
+// a form field or a multipart field. Templates can use {{csrfField}} and
+// {{csrfToken}}. Double-submit mode requires a Secret; without one the
+// middleware logs the problem and fails closed.
+func CSRF(config *CSRFConfig) MiddlewareFunc {
+	if config.Mode != CSRFSynchronizer && config.Secret == "" {
+		err := errors.New("csrf: double-submit mode requires a secret")
+		log.Print(err)
+		return func(c *Context) error {
+			return err
+		}
+	}
+	store := config.Store
+	if config.Mode == CSRFSynchronizer && store == nil {
+		store = NewMemoryStore()
+	}
+	errorHandler := config.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = func(c *Context, err error) error {
+			return c.String(http.StatusForbidden, "Forbidden: "+err.Error())
+		}
+	}
+
+	exempt := make(map[string]bool)
+	for _, route := range config.ExemptRoutes {
+		exempt[route] = true
+	}
+
+	return func(c *Context) error {
+		if config.Skipper != nil && config.Skipper(c) {
+			return c.Next()
+		}
+
+		token, err := config.token(c, store)
+		if err != nil {
+			return fmt.Errorf("csrf: %v", err)
+		}
+
+		c.Set("csrf_token", token)
+		c.SetTemplateFunc("csrfToken", func() string { return token })
+		c.SetTemplateFunc("csrfField", func() template.HTML {
+			return template.HTML(`<input type="hidden" name="` + html.EscapeString(config.FieldName) +
+				`" value="` + html.EscapeString(token) + `">`)
+		})
+
+		pattern := c.RoutePattern()
+		if isSafeMethod(c.Request.Method) || exempt[pattern] || exempt[c.Request.Method+" "+pattern] {
+			return c.Next()
+		}
+
+		submitted := config.submittedToken(c)
+		if submitted == "" {
+			return errorHandler(c, ErrCSRFTokenMissing)
+		}
+		if !hmac.Equal([]byte(submitted), []byte(token)) {
+			return errorHandler(c, ErrCSRFTokenInvalid)
+		}
+
+		return c.Next()
+	}
+}
+
+// token returns the token of the current client, issuing a new one if needed
+func (config *CSRFConfig) token(c *Context, store Store) (string, error) {
+	var cookieValue string
+	if cookie, err := c.Request.Cookie(config.CookieName); err == nil {
+		cookieValue = cookie.Value
+	}
+
+	if config.Mode == CSRFSynchronizer {
+		if cookieValue != "" {
+			stored, ok, err := store.Get(c.Request.Context(), "csrf:"+cookieValue)
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				return string(stored), nil
+			}
+		}
+
+		sessionID, err := randomToken(32)
+		if err != nil {
+			return "", err
+		}
+		token, err := randomToken(32)
+		if err != nil {
+			return "", err
+		}
+		if err := store.Set(c.Request.Context(), "csrf:"+sessionID, []byte(token), config.TTL); err != nil {
+			return "", err
+		}
+		config.setCookie(c, sessionID, true)
+		return token, nil
+	}
+
+	if cookieValue != "" && config.validSignedToken(cookieValue) {
+		return cookieValue, nil
+	}
+
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	token := raw + "." + config.sign(raw)
+	// Not HttpOnly so JavaScript clients can copy it into the header
+	config.setCookie(c, token, false)
+	return token, nil
+}
+
+// submittedToken reads the token from the header, form or multipart field
+func (config *CSRFConfig) submittedToken(c *Context) string {
+	if token := c.Request.Header.Get(config.HeaderName); token != "" {
+		return token
+	}
+
+	contentType := c.Request.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		if err := c.Request.ParseMultipartForm(config.MaxMemory); err != nil {
+			return ""
+		}
+		if values := c.Request.MultipartForm.Value[config.FieldName]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		return c.Request.PostFormValue(config.FieldName)
+	}
+
+	return ""
+}
+
+// setCookie writes the CSRF cookie
+func (config *CSRFConfig) setCookie(c *Context, value string, httpOnly bool) {
+	c.SetCookie(&http.Cookie{
+		Name:     config.CookieName,
+		Value:    value,
+		Path:     config.CookiePath,
+		Domain:   config.CookieDomain,
+		MaxAge:   int(config.TTL.Seconds()),
+		Secure:   config.CookieSecure,
+		HttpOnly: httpOnly,
+		SameSite: config.CookieSameSite,
+	})
+}
+
+// sign returns the HMAC of a raw double-submit token
+func (config *CSRFConfig) sign(raw string) string {
+	h := hmac.New(sha256.New, []byte(config.Secret))
+	h.Write([]byte("csrf:" + raw))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// validSignedToken verifies a "token.signature" double-submit value
+func (config *CSRFConfig) validSignedToken(value string) bool {
+	raw, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(config.sign(raw)))
+}
+
+// GetCSRFToken returns the CSRF token for the current request
+func GetCSRFToken(c *Context) string {
+	if token, ok := c.Get("csrf_token").(string); ok {
+		return token
+	}
+	return ""
+}
+
+// isSafeMethod reports whether a method is defined as safe by RFC 9110
+func isSafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
+
+// randomToken returns n random bytes encoded as URL-safe base64
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
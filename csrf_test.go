@@ -0,0 +1,142 @@
+package forge
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newCSRFApp(config *CSRFConfig) *Forge {
+	app := New()
+	app.Use(CSRF(config))
+	app.GET("/form", func(c *Context) error { return c.String(200, GetCSRFToken(c)) })
+	app.POST("/submit", func(c *Context) error { return c.String(200, "OK") })
+	app.POST("/webhook", func(c *Context) error { return c.String(200, "OK") })
+	return app
+}
+
+// fetchCSRFToken performs a GET and returns the token and cookie issued
+func fetchCSRFToken(t *testing.T, app *Forge) (string, *http.Cookie) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/form", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Expected a CSRF cookie, got %v", cookies)
+	}
+	return w.Body.String(), cookies[0]
+}
+
+func TestCSRFDoubleSubmitRequiresSecret(t *testing.T) {
+	app := newCSRFApp(NewCSRFConfig(""))
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/form", nil))
+	if w.Code != 500 || len(w.Result().Cookies()) != 0 {
+		t.Errorf("Expected unsigned double-submit config to fail closed, got %d %v", w.Code, w.Result().Cookies())
+	}
+
+	// The synchronizer mode stores tokens server-side and needs no secret
+	config := NewCSRFConfig("")
+	config.Mode = CSRFSynchronizer
+	fetchCSRFToken(t, newCSRFApp(config))
+	if config.Store != nil {
+		t.Error("Expected the default store not to be written to the caller's config")
+	}
+}
+
+func TestCSRFModes(t *testing.T) {
+	for _, mode := range []CSRFMode{CSRFDoubleSubmit, CSRFSynchronizer} {
+		config := NewCSRFConfig("secret")
+		config.Mode = mode
+		config.ExemptRoutes = []string{"POST /webhook"}
+		app := newCSRFApp(config)
+
+		token, cookie := fetchCSRFToken(t, app)
+
+		// Missing token
+		req := httptest.NewRequest("POST", "/submit", nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != 403 {
+			t.Errorf("%s: expected 403 without token, got %d", mode, w.Code)
+		}
+
+		// Header token
+		req = httptest.NewRequest("POST", "/submit", nil)
+		req.AddCookie(cookie)
+		req.Header.Set("X-CSRF-Token", token)
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != 200 {
+			t.Errorf("%s: expected 200 with header token, got %d", mode, w.Code)
+		}
+
+		// Form field token
+		form := url.Values{"_csrf": {token}}
+		req = httptest.NewRequest("POST", "/submit", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != 200 {
+			t.Errorf("%s: expected 200 with form token, got %d", mode, w.Code)
+		}
+
+		// Token without the matching cookie
+		req = httptest.NewRequest("POST", "/submit", nil)
+		req.Header.Set("X-CSRF-Token", token)
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != 403 {
+			t.Errorf("%s: expected 403 without cookie, got %d", mode, w.Code)
+		}
+
+		// Exempt route
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest("POST", "/webhook", nil))
+		if w.Code != 200 {
+			t.Errorf("%s: expected exempt route to pass, got %d", mode, w.Code)
+		}
+	}
+}
+
+func TestCSRFMultipartField(t *testing.T) {
+	app := newCSRFApp(NewCSRFConfig("secret"))
+	token, cookie := fetchCSRFToken(t, app)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("_csrf", token)
+	part, _ := mw.CreateFormFile("file", "notes.txt")
+	part.Write([]byte("hello"))
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/submit", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Errorf("Expected multipart token to be accepted, got %d", w.Code)
+	}
+}
+
+func TestCSRFForgedDoubleSubmitCookie(t *testing.T) {
+	app := newCSRFApp(NewCSRFConfig("secret"))
+
+	req := httptest.NewRequest("POST", "/submit", nil)
+	req.AddCookie(&http.Cookie{Name: "_csrf", Value: "forged.value"})
+	req.Header.Set("X-CSRF-Token", "forged.value")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != 403 {
+		t.Errorf("Expected unsigned cookie to be rejected, got %d", w.Code)
+	}
+}
@@ -0,0 +1,76 @@
+package forge
+
+import (
+	"errors"
+	"log"
+	"net/http"
+)
+
+// HTTPError is an error carrying an HTTP status. Handlers and middleware
+// return it to let the central error handler write the response.
+type HTTPError struct {
+	Code     int
+	Message  string
+	Internal error // Logged by the default handler, never sent to the client
+}
+
+// Common HTTP errors
+var (
+	ErrBadRequest   = NewHTTPError(http.StatusBadRequest)
+	ErrUnauthorized = NewHTTPError(http.StatusUnauthorized)
+	ErrForbidden    = NewHTTPError(http.StatusForbidden)
+	ErrNotFound     = NewHTTPError(http.StatusNotFound)
+)
+
+// NewHTTPError creates an HTTP error. The message defaults to the status text.
+func NewHTTPError(code int, message ...string) *HTTPError {
+	e := &HTTPError{Code: code, Message: http.StatusText(code)}
+	if len(message) > 0 {
+		e.Message = message[0]
+	}
+	return e
+}
+
+// Error returns the message sent to the client
+func (e *HTTPError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the internal error
+func (e *HTTPError) Unwrap() error {
+	return e.Internal
+}
+
+// WithInternal returns a copy of the error wrapping an internal cause
+func (e *HTTPError) WithInternal(err error) *HTTPError {
+	copied := *e
+	copied.Internal = err
+	return &copied
+}
+
+// ErrorHandler writes the response for an error returned by the middleware chain
+type ErrorHandler func(c *Context, err error)
+
+// DefaultErrorHandler sends HTTPErrors as JSON with their status. Any other
+// error is answered with 500.
+func DefaultErrorHandler(c *Context, err error) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		if httpErr.Internal != nil {
+			log.Printf("%d %s: %v", httpErr.Code, httpErr.Message, httpErr.Internal)
+		}
+		c.JSON(httpErr.Code, map[string]string{"error": httpErr.Message})
+		return
+	}
+	http.Error(c.Response, err.Error(), http.StatusInternalServerError)
+}
+
+// SetErrorHandler sets the central error handler (nil restores the default)
+func (f *Forge) SetErrorHandler(handler ErrorHandler) {
+	if handler == nil {
+		handler = DefaultErrorHandler
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errorHandler = handler
+}
@@ -155,11 +155,15 @@ func main() {
 		// Broadcast to all connections
 		broadcaster.Broadcast("New user joined the chat!")
 		
-		// Keep connection alive (in a real app, you'd handle incoming messages)
-		time.Sleep(30 * time.Second)
-		
-		broadcaster.RemoveConnection(conn)
-		conn.Close()
+		// Relay incoming messages until the client leaves; closed
+		// connections are removed from the broadcaster automatically
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			broadcaster.Broadcast(string(message))
+		}
 	})
 	
 	// WebSocket broadcast endpoint
@@ -7,10 +7,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"regexp"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -27,6 +28,8 @@ type Context struct {
 	locals     map[string]interface{}
 	middleware []MiddlewareFunc
 	index      int
+	route      *Route
+	forge      *Forge
 	mu         sync.RWMutex
 }
 
@@ -35,11 +38,12 @@ type HandlerFunc func(*Context) error
 
 // Route represents a single route with its pattern and handler
 type Route struct {
-	Method  string
-	Pattern string
-	Handler HandlerFunc
-	Regex   *regexp.Regexp
-	Keys    []string
+	Method     string
+	Pattern    string
+	Handler    HandlerFunc
+	Middleware []MiddlewareFunc
+	Regex      *regexp.Regexp
+	Keys       []string
 }
 
 // Forge is the main framework struct
@@ -50,13 +54,21 @@ type Forge struct {
 	server         *http.Server
 	templateEngine *TemplateEngine
 	hotReload      *HotReload
+	shuttingDown   atomic.Bool
+	shutdownDelay  time.Duration
+	trustedProxies []*net.IPNet
+	cookiePolicy   CookiePolicy
+	cookieKeys     *cookieKeys
+	errorHandler   ErrorHandler
 }
 
 // New creates a new Forge instance
 func New() *Forge {
 	return &Forge{
-		routes:     make([]*Route, 0),
-		middleware: make([]MiddlewareFunc, 0),
+		routes:       make([]*Route, 0),
+		middleware:   make([]MiddlewareFunc, 0),
+		cookiePolicy: DefaultCookiePolicy(),
+		errorHandler: DefaultErrorHandler,
 	}
 }
 
@@ -109,20 +121,27 @@ func (c *Context) Next() error {
 	return nil
 }
 
+// RoutePattern returns the pattern of the matched route (e.g. "/users/:id")
+func (c *Context) RoutePattern() string {
+	if c.route == nil {
+		return ""
+	}
+	return c.route.Pattern
+}
+
 // Header sets a response header
 func (c *Context) Header(key, value string) {
 	c.Response.Header().Set(key, value)
 }
 
-// Cookie sets a cookie
+// Cookie sets an HttpOnly cookie, applying the app's cookie policy
 func (c *Context) Cookie(name, value string, maxAge int) {
-	cookie := &http.Cookie{
-		Name:   name,
-		Value:  value,
-		MaxAge: maxAge,
-		Path:   "/",
-	}
-	http.SetCookie(c.Response, cookie)
+	c.SetCookie(&http.Cookie{
+		Name:     name,
+		Value:    value,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+	})
 }
 
 // Forge methods
@@ -132,14 +151,15 @@ func (f *Forge) Use(middleware MiddlewareFunc) {
 	f.middleware = append(f.middleware, middleware)
 }
 
-func (f *Forge) addRoute(method, pattern string, handler HandlerFunc) {
+func (f *Forge) addRoute(method, pattern string, handler HandlerFunc, middleware ...MiddlewareFunc) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	
 	route := &Route{
-		Method:  method,
-		Pattern: pattern,
-		Handler: handler,
+		Method:     method,
+		Pattern:    pattern,
+		Handler:    handler,
+		Middleware: middleware,
 	}
 	
 	// Convert Express-style routes to regex
@@ -201,6 +221,7 @@ func (f *Forge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Query:    make(map[string]string),
 		locals:   make(map[string]interface{}),
 		index:    -1,
+		forge:    f,
 	}
 	
 	// Parse query parameters
@@ -212,35 +233,53 @@ func (f *Forge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	
 	// Find matching route
 	var matchedRoute *Route
+	var pathRoutes []*Route
 	f.mu.RLock()
+	globalMiddleware := f.middleware
+	errorHandler := f.errorHandler
 	for _, route := range f.routes {
-		if route.Method == r.Method && route.Regex.MatchString(r.URL.Path) {
+		if !route.Regex.MatchString(r.URL.Path) {
+			continue
+		}
+		if route.Method == r.Method {
 			matchedRoute = route
-			
-			// Extract parameters
-			matches := route.Regex.FindStringSubmatch(r.URL.Path)
-			for i, key := range route.Keys {
-				if i+1 < len(matches) {
-					ctx.Params[key] = matches[i+1]
-				}
-			}
 			break
 		}
+		pathRoutes = append(pathRoutes, route)
 	}
 	f.mu.RUnlock()
 	
+	// Answer OPTIONS automatically for paths served with other methods, so
+	// middleware such as CORS can handle preflight requests
+	if matchedRoute == nil && r.Method == "OPTIONS" && len(pathRoutes) > 0 {
+		matchedRoute = automaticOptionsRoute(pathRoutes)
+	}
+	
 	if matchedRoute == nil {
 		http.NotFound(w, r)
 		return
 	}
 	
+	// Extract parameters
+	matches := matchedRoute.Regex.FindStringSubmatch(r.URL.Path)
+	for i, key := range matchedRoute.Keys {
+		if i+1 < len(matches) {
+			ctx.Params[key] = matches[i+1]
+		}
+	}
+	
+	ctx.route = matchedRoute
+	
 	// Set template engine in context if available
 	if f.templateEngine != nil {
 		ctx.Set("template_engine", f.templateEngine)
 	}
 	
-	// Build middleware chain
-	ctx.middleware = append(f.middleware, func(c *Context) error {
+	// Build middleware chain: global, then group, then the route handler
+	ctx.middleware = make([]MiddlewareFunc, 0, len(globalMiddleware)+len(matchedRoute.Middleware)+1)
+	ctx.middleware = append(ctx.middleware, globalMiddleware...)
+	ctx.middleware = append(ctx.middleware, matchedRoute.Middleware...)
+	ctx.middleware = append(ctx.middleware, func(c *Context) error {
 		return matchedRoute.Handler(c)
 	})
 	
@@ -248,7 +287,7 @@ func (f *Forge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if len(ctx.middleware) > 0 {
 		ctx.index = 0
 		if err := ctx.middleware[0](ctx); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			errorHandler(ctx, err)
 		}
 	}
 }
@@ -267,6 +306,17 @@ func (f *Forge) Listen(addr string) error {
 }
 
 func (f *Forge) Shutdown(ctx context.Context) error {
+	// Flip readiness first so load balancers stop routing new traffic
+	f.shuttingDown.Store(true)
+
+	if f.shutdownDelay > 0 {
+		select {
+		case <-time.After(f.shutdownDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
 	if f.server != nil {
 		fmt.Println("🛑 Shutting down Forge server...")
 		return f.server.Shutdown(ctx)
@@ -274,6 +324,17 @@ func (f *Forge) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// SetShutdownDelay sets how long Shutdown keeps serving with failing
+// readiness before closing listeners, giving load balancers time to drain
+func (f *Forge) SetShutdownDelay(delay time.Duration) {
+	f.shutdownDelay = delay
+}
+
+// ShuttingDown reports whether Shutdown has been called
+func (f *Forge) ShuttingDown() bool {
+	return f.shuttingDown.Load()
+}
+
 // Built-in middleware
 func Logger() MiddlewareFunc {
 	return func(c *Context) error {
@@ -286,90 +347,11 @@ func Logger() MiddlewareFunc {
 			status = 500
 		}
 		
-		log.Printf("[%d] %s %s - %v", status, c.Request.Method, c.Request.URL.Path, duration)
+		log.Printf("[%d] %s %s %s - %v", status, c.RealIP(), c.Request.Method, c.Request.URL.Path, duration)
 		return err
 	}
 }
 
-func CORS() MiddlewareFunc {
-	return func(c *Context) error {
-		c.Response.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Response.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		c.Response.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		
-		if c.Request.Method == "OPTIONS" {
-			c.Response.WriteHeader(http.StatusOK)
-			return nil
-		}
-		
-		return c.Next()
-	}
-}
-
-func RateLimiter(requests int, window time.Duration) MiddlewareFunc {
-	type client struct {
-		requests int
-		window   time.Time
-	}
-	
-	clients := make(map[string]*client)
-	mu := sync.RWMutex{}
-	
-	// Create context for cleanup routine lifecycle management
-	ctx, cancel := context.WithCancel(context.Background())
-	
-	// Cleanup routine with proper lifecycle management
-	go func() {
-		ticker := time.NewTicker(window)
-		defer ticker.Stop()
-		
-		for {
-			select {
-			case <-ctx.Done():
-				return // Gracefully exit when context is cancelled
-			case <-ticker.C:
-				mu.Lock()
-				now := time.Now()
-				for ip, cl := range clients {
-					if now.Sub(cl.window) > window {
-						delete(clients, ip)
-					}
-				}
-				mu.Unlock()
-			}
-		}
-	}()
-	
-	// Store cancel function for potential cleanup (in a real implementation,
-	// this would be managed by the Forge instance lifecycle)
-	_ = cancel // TODO: Integrate with Forge shutdown
-	
-	return func(c *Context) error {
-		ip := c.Request.RemoteAddr
-		if forwarded := c.Request.Header.Get("X-Forwarded-For"); forwarded != "" {
-			ip = strings.Split(forwarded, ",")[0]
-		}
-		
-		mu.Lock()
-		defer mu.Unlock()
-		
-		now := time.Now()
-		cl, exists := clients[ip]
-		
-		if !exists || now.Sub(cl.window) > window {
-			clients[ip] = &client{requests: 1, window: now}
-			return c.Next()
-		}
-		
-		if cl.requests >= requests {
-			return c.String(429, "Rate limit exceeded")
-		}
-		
-		cl.requests++
-		return c.Next()
-	}
-}
-
 // Recovery middleware
 func Recovery() MiddlewareFunc {
 	return func(c *Context) error {
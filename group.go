@@ -0,0 +1,110 @@
+package forge
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Group represents a set of routes sharing a path prefix and middleware
+type Group struct {
+	forge      *Forge
+	prefix     string
+	middleware []MiddlewareFunc
+}
+
+// Group creates a new route group. Group middleware runs after the global
+// middleware and only for routes registered on the group.
+func (f *Forge) Group(prefix string, middleware ...MiddlewareFunc) *Group {
+	return &Group{
+		forge:      f,
+		prefix:     strings.TrimSuffix(prefix, "/"),
+		middleware: middleware,
+	}
+}
+
+// Group creates a nested group inheriting the prefix and middleware
+func (g *Group) Group(prefix string, middleware ...MiddlewareFunc) *Group {
+	return &Group{
+		forge:      g.forge,
+		prefix:     g.prefix + strings.TrimSuffix(prefix, "/"),
+		middleware: append(g.routeMiddleware(), middleware...),
+	}
+}
+
+// Use adds middleware to routes registered on the group afterwards
+func (g *Group) Use(middleware MiddlewareFunc) {
+	g.middleware = append(g.middleware, middleware)
+}
+
+// routeMiddleware returns a copy of the group middleware for a new route
+func (g *Group) routeMiddleware() []MiddlewareFunc {
+	return append([]MiddlewareFunc(nil), g.middleware...)
+}
+
+func (g *Group) GET(pattern string, handler HandlerFunc) {
+	g.forge.addRoute("GET", g.prefix+pattern, handler, g.routeMiddleware()...)
+}
+
+func (g *Group) POST(pattern string, handler HandlerFunc) {
+	g.forge.addRoute("POST", g.prefix+pattern, handler, g.routeMiddleware()...)
+}
+
+func (g *Group) PUT(pattern string, handler HandlerFunc) {
+	g.forge.addRoute("PUT", g.prefix+pattern, handler, g.routeMiddleware()...)
+}
+
+func (g *Group) DELETE(pattern string, handler HandlerFunc) {
+	g.forge.addRoute("DELETE", g.prefix+pattern, handler, g.routeMiddleware()...)
+}
+
+func (g *Group) PATCH(pattern string, handler HandlerFunc) {
+	g.forge.addRoute("PATCH", g.prefix+pattern, handler, g.routeMiddleware()...)
+}
+
+func (g *Group) OPTIONS(pattern string, handler HandlerFunc) {
+	g.forge.addRoute("OPTIONS", g.prefix+pattern, handler, g.routeMiddleware()...)
+}
+
+// WebSocket registers a WebSocket route; group middleware runs before the upgrade
+func (g *Group) WebSocket(pattern string, handler WebSocketHandler) {
+	g.WebSocketWithConfig(pattern, NewWebSocketConfig(), handler)
+}
+
+// WebSocketWithConfig registers a WebSocket route with an upgrade configuration.
+// Group middleware runs first, then the config middleware.
+func (g *Group) WebSocketWithConfig(pattern string, config *WebSocketConfig, handler WebSocketHandler) {
+	middleware := append(g.routeMiddleware(), config.Middleware...)
+	g.forge.addRoute("GET", g.prefix+pattern, func(c *Context) error {
+		return g.forge.upgradeWebSocket(c, config, handler)
+	}, middleware...)
+}
+
+// automaticOptionsRoute builds an OPTIONS route for a path registered only
+// with other methods. It answers 204 with an Allow header and runs the
+// middleware of the first matching route.
+func automaticOptionsRoute(routes []*Route) *Route {
+	methods := map[string]bool{"OPTIONS": true}
+	for _, route := range routes {
+		methods[route.Method] = true
+	}
+	allow := make([]string, 0, len(methods))
+	for method := range methods {
+		allow = append(allow, method)
+	}
+	sort.Strings(allow)
+
+	first := routes[0]
+	return &Route{
+		Method:     "OPTIONS",
+		Pattern:    first.Pattern,
+		Middleware: first.Middleware,
+		Regex:      first.Regex,
+		Keys:       first.Keys,
+		Handler: func(c *Context) error {
+			c.Header("Allow", strings.Join(allow, ", "))
+			c.Response.WriteHeader(http.StatusNoContent)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,241 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Health check status values
+const (
+	HealthStatusPass = "pass"
+	HealthStatusFail = "fail"
+)
+
+// HealthCheckFunc reports the health of a single dependency
+type HealthCheckFunc func(ctx context.Context) error
+
+// HealthCheck represents a named check registered on a HealthChecker
+type HealthCheck struct {
+	Name     string          // Name reported in the JSON output
+	Check    HealthCheckFunc // Function that performs the check
+	Timeout  time.Duration   // Per-check timeout (defaults to HealthChecker.Timeout)
+	CacheTTL time.Duration   // How long a result is reused (0 disables caching)
+	Liveness bool            // Also run the check on the liveness endpoint
+}
+
+// HealthCheckResult represents the outcome of a single check
+type HealthCheckResult struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Latency   string    `json:"latency"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	Cached    bool      `json:"cached"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// HealthReport represents the JSON report returned by the health endpoints
+type HealthReport struct {
+	Status string              `json:"status"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+// HealthChecker runs registered checks for the health, liveness and readiness endpoints
+type HealthChecker struct {
+	Timeout time.Duration // Default per-check timeout
+	forge   *Forge
+	checks  []*healthEntry
+	mu      sync.RWMutex
+}
+
+type healthEntry struct {
+	check   HealthCheck
+	mu      sync.Mutex
+	last    HealthCheckResult
+	expires time.Time
+}
+
+// NewHealthChecker creates a new health checker bound to a Forge instance.
+// The Forge instance may be nil, in which case readiness never drains.
+func NewHealthChecker(f *Forge) *HealthChecker {
+	return &HealthChecker{
+		Timeout: 5 * time.Second,
+		forge:   f,
+		checks:  make([]*healthEntry, 0),
+	}
+}
+
+// Health registers the health endpoints under path and returns the checker.
+// The path itself reports every check, path/livez only liveness checks and
+// path/readyz every check plus the shutdown state of the server.
+func (f *Forge) Health(path string) *HealthChecker {
+	hc := NewHealthChecker(f)
+	base := strings.TrimSuffix(path, "/")
+
+	f.GET(path, hc.handler(false, true))
+	f.GET(base+"/livez", hc.handler(true, false))
+	f.GET(base+"/readyz", hc.handler(false, true))
+
+	return hc
+}
+
+// AddCheck registers a health check
+func (hc *HealthChecker) AddCheck(check HealthCheck) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.checks = append(hc.checks, &healthEntry{check: check})
+}
+
+// AddReadinessCheck registers a check that only affects readiness
+func (hc *HealthChecker) AddReadinessCheck(name string, fn HealthCheckFunc) {
+	hc.AddCheck(HealthCheck{Name: name, Check: fn})
+}
+
+// AddLivenessCheck registers a check that affects both liveness and readiness
+func (hc *HealthChecker) AddLivenessCheck(name string, fn HealthCheckFunc) {
+	hc.AddCheck(HealthCheck{Name: name, Check: fn, Liveness: true})
+}
+
+// Liveness runs the liveness checks
+func (hc *HealthChecker) Liveness(ctx context.Context) HealthReport {
+	return hc.run(ctx, true, false)
+}
+
+// Readiness runs every check and fails while the server is shutting down
+func (hc *HealthChecker) Readiness(ctx context.Context) HealthReport {
+	return hc.run(ctx, false, true)
+}
+
+// run executes the selected checks concurrently and builds the report
+func (hc *HealthChecker) run(ctx context.Context, livenessOnly, readiness bool) HealthReport {
+	hc.mu.RLock()
+	entries := make([]*healthEntry, 0, len(hc.checks))
+	for _, entry := range hc.checks {
+		if !livenessOnly || entry.check.Liveness {
+			entries = append(entries, entry)
+		}
+	}
+	hc.mu.RUnlock()
+
+	report := HealthReport{
+		Status: HealthStatusPass,
+		Checks: make([]HealthCheckResult, len(entries)),
+	}
+
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		wg.Add(1)
+		go func(i int, entry *healthEntry) {
+			defer wg.Done()
+			report.Checks[i] = entry.result(ctx, hc.Timeout)
+		}(i, entry)
+	}
+	wg.Wait()
+
+	if readiness && hc.forge != nil && hc.forge.ShuttingDown() {
+		report.Checks = append(report.Checks, HealthCheckResult{
+			Name:      "shutdown",
+			Status:    HealthStatusFail,
+			Latency:   "0s",
+			Error:     "server is shutting down",
+			CheckedAt: time.Now(),
+		})
+	}
+
+	for _, result := range report.Checks {
+		if result.Status != HealthStatusPass {
+			report.Status = HealthStatusFail
+			break
+		}
+	}
+
+	return report
+}
+
+// result returns the cached result or runs the check with its timeout
+func (e *healthEntry) result(ctx context.Context, defaultTimeout time.Duration) HealthCheckResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	if e.check.CacheTTL > 0 && now.Before(e.expires) {
+		cached := e.last
+		cached.Cached = true
+		return cached
+	}
+
+	timeout := e.check.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := runHealthCheck(checkCtx, e.check.Check)
+	latency := time.Since(start)
+
+	result := HealthCheckResult{
+		Name:      e.check.Name,
+		Status:    HealthStatusPass,
+		Latency:   latency.String(),
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+		CheckedAt: now,
+	}
+	if err != nil {
+		result.Status = HealthStatusFail
+		result.Error = err.Error()
+	}
+
+	// A failure caused by the caller going away says nothing about the
+	// dependency, so it must not be served to other callers
+	if ctx.Err() == nil {
+		e.last = result
+		e.expires = now.Add(e.check.CacheTTL)
+	}
+	return result
+}
+
+// runHealthCheck runs fn and returns early if the context expires first
+func runHealthCheck(ctx context.Context, fn HealthCheckFunc) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("check timed out: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("check panicked: %v", r)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("check timed out: %v", ctx.Err())
+	}
+}
+
+// handler serves a health report as JSON
+func (hc *HealthChecker) handler(livenessOnly, readiness bool) HandlerFunc {
+	return func(c *Context) error {
+		report := hc.run(c.Request.Context(), livenessOnly, readiness)
+
+		status := http.StatusOK
+		if report.Status != HealthStatusPass {
+			status = http.StatusServiceUnavailable
+		}
+
+		c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+		return c.JSON(status, report)
+	}
+}
@@ -0,0 +1,162 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	app := New()
+	hc := app.Health("/health")
+	hc.AddLivenessCheck("goroutines", func(ctx context.Context) error { return nil })
+	hc.AddReadinessCheck("database", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		checks int
+	}{
+		{"/health", 503, 2},
+		{"/health/livez", 200, 1},
+		{"/health/readyz", 503, 2},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.status, w.Code)
+		}
+
+		var report HealthReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("%s: invalid JSON: %v", tt.path, err)
+		}
+		if len(report.Checks) != tt.checks {
+			t.Errorf("%s: expected %d checks, got %d", tt.path, tt.checks, len(report.Checks))
+		}
+	}
+}
+
+func TestHealthReadinessFailsOnShutdown(t *testing.T) {
+	app := New()
+	app.Health("/")
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("Expected ready before shutdown, got %d", w.Code)
+	}
+
+	app.Shutdown(context.Background())
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != 503 {
+		t.Errorf("Expected 503 after shutdown, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/livez", nil))
+	if w.Code != 200 {
+		t.Errorf("Liveness should not depend on shutdown, got %d", w.Code)
+	}
+}
+
+func TestHealthReadinessFailsDuringShutdownDelay(t *testing.T) {
+	app := New()
+	app.Health("/")
+	app.GET("/work", func(c *Context) error { return c.String(200, "ok") })
+	app.SetShutdownDelay(200 * time.Millisecond)
+
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() { done <- app.Shutdown(context.Background()) }()
+
+	deadline := time.Now().Add(time.Second)
+	for !app.ShuttingDown() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != 503 {
+		t.Errorf("Expected 503 during the shutdown delay, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/work", nil))
+	if w.Code != 200 {
+		t.Errorf("Expected requests to be served during the shutdown delay, got %d", w.Code)
+	}
+
+	select {
+	case <-done:
+		t.Fatal("Expected Shutdown to wait for the delay")
+	default:
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected Shutdown to take at least the delay, took %v", elapsed)
+	}
+}
+
+func TestHealthCheckTimeoutAndCache(t *testing.T) {
+	hc := NewHealthChecker(nil)
+	var calls int32
+	hc.AddCheck(HealthCheck{
+		Name:     "slow",
+		Timeout:  10 * time.Millisecond,
+		CacheTTL: time.Minute,
+		Check: func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	first := hc.Readiness(context.Background())
+	if first.Status != HealthStatusFail || first.Checks[0].Cached {
+		t.Fatalf("Expected uncached failure, got %+v", first)
+	}
+
+	second := hc.Readiness(context.Background())
+	if !second.Checks[0].Cached || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected cached result with 1 call, got cached=%v calls=%d", second.Checks[0].Cached, atomic.LoadInt32(&calls))
+	}
+}
+
+func TestHealthCheckDoesNotCacheCancelledRequest(t *testing.T) {
+	hc := NewHealthChecker(nil)
+	var calls int32
+	hc.AddCheck(HealthCheck{
+		Name:     "db",
+		CacheTTL: time.Minute,
+		Check: func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			return ctx.Err()
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if report := hc.Readiness(ctx); report.Status != HealthStatusFail {
+		t.Fatalf("Expected failure for cancelled request, got %+v", report)
+	}
+
+	report := hc.Readiness(context.Background())
+	if report.Status != HealthStatusPass || report.Checks[0].Cached {
+		t.Errorf("Expected fresh passing result, got %+v", report)
+	}
+}
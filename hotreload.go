@@ -210,8 +210,12 @@ func (f *Forge) ListenWithHotReload(addr string, watchDirs ...string) error {
 	return f.Listen(addr)
 }
 
-// Hot reload for templates
+// Hot reload for templates (on-disk engines only; embedded templates never change)
 func (te *TemplateEngine) EnableHotReload() {
+	if te.baseDir == "" {
+		log.Println("⚠️ Hot reload needs an on-disk template directory - skipped")
+		return
+	}
 	hr := NewHotReload()
 	hr.Enable()
 	hr.AddWatchDir(te.baseDir)
@@ -0,0 +1,351 @@
+package forge
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWTKeySet resolves verification keys by key ID ("kid")
+type JWTKeySet interface {
+	Key(kid, alg string) (crypto.PublicKey, error)
+}
+
+// ErrJWKNotFound is returned when no key matches a token's kid
+var ErrJWKNotFound = errors.New("signing key not found")
+
+// JWK represents a JSON Web Key (RFC 7517) holding a public key
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKSet represents a JSON Web Key Set document
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK encodes an RSA, ECDSA or Ed25519 public key as a JWK
+func NewJWK(kid, alg string, key crypto.PublicKey) (JWK, error) {
+	jwk := JWK{KeyID: kid, Algorithm: alg, Use: "sig"}
+	enc := base64.RawURLEncoding
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = enc.EncodeToString(k.N.Bytes())
+		jwk.E = enc.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jwk.KeyType = "EC"
+		jwk.Curve = k.Curve.Params().Name
+		size := (k.Curve.Params().BitSize + 7) / 8
+		jwk.X = enc.EncodeToString(k.X.FillBytes(make([]byte, size)))
+		jwk.Y = enc.EncodeToString(k.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = enc.EncodeToString(k)
+	default:
+		return JWK{}, fmt.Errorf("unsupported key type %T", key)
+	}
+	return jwk, nil
+}
+
+// PublicKey decodes the JWK into an RSA, ECDSA or Ed25519 public key
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	dec := base64.RawURLEncoding
+
+	switch k.KeyType {
+	case "RSA":
+		n, err := dec.DecodeString(k.N)
+		if err != nil || len(n) == 0 {
+			return nil, errors.New("invalid RSA modulus")
+		}
+		e, err := dec.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		var check ecdh.Curve
+		switch k.Curve {
+		case "P-256":
+			curve, check = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, check = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, check = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Curve)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		x, errX := dec.DecodeString(k.X)
+		y, errY := dec.DecodeString(k.Y)
+		if errX != nil || errY != nil || len(x) != size || len(y) != size {
+			return nil, errors.New("invalid EC coordinates")
+		}
+		// Reject points that are not on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := check.NewPublicKey(point); err != nil {
+			return nil, errors.New("invalid EC point")
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Curve)
+		}
+		x, err := dec.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", k.KeyType)
+}
+
+// JWKSConfig represents JWKS provider configuration
+type JWKSConfig struct {
+	URL                string        // Remote JWKS endpoint
+	File               string        // Local JWKS file (used when URL is empty)
+	RefreshInterval    time.Duration // How long fetched keys are cached
+	MinRefreshInterval time.Duration // Minimum delay between refreshes triggered by unknown kids
+	Timeout            time.Duration // Timeout for remote fetches
+	Client             *http.Client
+}
+
+// NewJWKSConfig creates a JWKS configuration. Sources starting with
+// http:// or https:// are fetched; anything else is read as a file.
+func NewJWKSConfig(source string) *JWKSConfig {
+	config := &JWKSConfig{
+		RefreshInterval:    time.Hour,
+		MinRefreshInterval: time.Minute,
+		Timeout:            5 * time.Second,
+		Client:             http.DefaultClient,
+	}
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		config.URL = source
+	} else {
+		config.File = source
+	}
+	return config
+}
+
+// JWKS provides verification keys from a JSON Web Key Set. Keys are
+// loaded lazily, cached for RefreshInterval and refreshed early when a
+// token references an unknown kid. Stale keys are kept if a refresh fails.
+// Fetches run outside the lock, one at a time: expired keys keep being
+// served while the set is refreshed in the background.
+type JWKS struct {
+	config      *JWKSConfig
+	mu          sync.Mutex
+	keys        map[string]jwksEntry
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	err         error
+	refreshing  chan struct{} // Closed when the running fetch completes
+}
+
+type jwksEntry struct {
+	alg string
+	key crypto.PublicKey
+}
+
+// NewJWKS creates a new JWKS provider
+func NewJWKS(config *JWKSConfig) *JWKS {
+	return &JWKS{config: config}
+}
+
+// Key returns the key with the given kid, checking it may be used with alg.
+// An empty kid selects the only key of a single-key set. Only a kid missing
+// from the cached set waits for a fetch.
+func (j *JWKS) Key(kid, alg string) (crypto.PublicKey, error) {
+	j.mu.Lock()
+	now := time.Now()
+	if now.Sub(j.fetchedAt) > j.config.RefreshInterval && j.canRefreshLocked(now) {
+		j.startRefreshLocked(now)
+	}
+
+	entry, ok := j.lookupLocked(kid)
+	if !ok {
+		done := j.refreshing
+		if done == nil && j.canRefreshLocked(now) {
+			done = j.startRefreshLocked(now)
+		}
+		if done != nil {
+			j.mu.Unlock()
+			<-done
+			j.mu.Lock()
+			entry, ok = j.lookupLocked(kid)
+		}
+	}
+	err := j.err
+	j.mu.Unlock()
+
+	if !ok {
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrJWKNotFound
+	}
+	if entry.alg != "" && entry.alg != alg {
+		return nil, fmt.Errorf("key %q is restricted to %s", kid, entry.alg)
+	}
+	return entry.key, nil
+}
+
+// Refresh reloads the key set immediately
+func (j *JWKS) Refresh(ctx context.Context) error {
+	now := time.Now()
+	j.mu.Lock()
+	j.attemptedAt = now
+	j.mu.Unlock()
+
+	keys, err := j.fetch(ctx)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.storeLocked(keys, err, now)
+}
+
+func (j *JWKS) lookupLocked(kid string) (jwksEntry, bool) {
+	if kid == "" && len(j.keys) == 1 {
+		for _, entry := range j.keys {
+			return entry, true
+		}
+	}
+	entry, ok := j.keys[kid]
+	return entry, ok
+}
+
+// canRefreshLocked throttles refreshes so unknown kids or an unreachable
+// endpoint cannot trigger a fetch on every request
+func (j *JWKS) canRefreshLocked(now time.Time) bool {
+	return j.attemptedAt.IsZero() || now.Sub(j.attemptedAt) >= j.config.MinRefreshInterval
+}
+
+// startRefreshLocked starts a background fetch unless one is running and
+// returns the channel closed when it completes; the caller holds j.mu
+func (j *JWKS) startRefreshLocked(now time.Time) chan struct{} {
+	if j.refreshing != nil {
+		return j.refreshing
+	}
+	j.attemptedAt = now
+	done := make(chan struct{})
+	j.refreshing = done
+
+	go func() {
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if j.config.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, j.config.Timeout)
+		}
+		keys, err := j.fetch(ctx)
+		cancel()
+
+		j.mu.Lock()
+		j.storeLocked(keys, err, now)
+		j.refreshing = nil
+		j.mu.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// fetch reads and parses the key set without holding j.mu
+func (j *JWKS) fetch(ctx context.Context) (map[string]jwksEntry, error) {
+	data, err := j.read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWKS: %v", err)
+	}
+
+	var set JWKSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %v", err)
+	}
+
+	keys := make(map[string]jwksEntry, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			// Skip keys we cannot use rather than rejecting the whole set
+			continue
+		}
+		keys[jwk.KeyID] = jwksEntry{alg: jwk.Algorithm, key: key}
+	}
+	return keys, nil
+}
+
+// storeLocked records the outcome of a fetch started at fetchedAt, keeping
+// the previous keys when it failed; the caller holds j.mu
+func (j *JWKS) storeLocked(keys map[string]jwksEntry, err error, fetchedAt time.Time) error {
+	if err != nil {
+		j.err = err
+		return err
+	}
+	if fetchedAt.Before(j.fetchedAt) {
+		return nil // A fetch started later already stored newer keys
+	}
+	j.keys = keys
+	j.fetchedAt = fetchedAt
+	j.err = nil
+	return nil
+}
+
+func (j *JWKS) read(ctx context.Context) ([]byte, error) {
+	if j.config.URL == "" {
+		return os.ReadFile(j.config.File)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", j.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	client := j.config.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
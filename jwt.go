@@ -1,8 +1,7 @@
 package forge
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
+	"crypto"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -23,13 +22,23 @@ type JWT struct {
 type JWTHeader struct {
 	Algorithm string `json:"alg"`
 	Type      string `json:"typ"`
+	KeyID     string `json:"kid,omitempty"`
 }
 
+// JWT validation errors
+var (
+	ErrTokenMalformed        = errors.New("invalid token format")
+	ErrTokenUnverifiable     = errors.New("token cannot be verified")
+	ErrTokenSignatureInvalid = errors.New("invalid signature")
+	ErrTokenMissing          = errors.New("missing authorization token")
+	ErrInvalidAuthHeader     = errors.New("invalid authorization header format")
+)
+
 // JWTPayload represents the JWT payload
 type JWTPayload struct {
 	Issuer         string                 `json:"iss,omitempty"`
 	Subject        string                 `json:"sub,omitempty"`
-	Audience       string                 `json:"aud,omitempty"`
+	Audience       JWTAudience            `json:"aud,omitempty"`
 	ExpirationTime int64                  `json:"exp,omitempty"`
 	NotBefore      int64                  `json:"nbf,omitempty"`
 	IssuedAt       int64                  `json:"iat,omitempty"`
@@ -39,19 +48,34 @@ type JWTPayload struct {
 
 // JWTConfig represents JWT configuration
 type JWTConfig struct {
-	Secret     string
-	Issuer     string
-	Expiration time.Duration
-	Algorithm  string
+	Secret            string            // HMAC secret for HS256/384/512
+	Issuer            string
+	Expiration        time.Duration
+	Algorithm         string            // Algorithm used to sign new tokens
+	PrivateKey        crypto.PrivateKey // Signing key for RS*, PS*, ES* and EdDSA
+	KeyID             string            // kid written into new tokens
+	PublicKey         crypto.PublicKey  // Verification key (defaults to PrivateKey's public key)
+	KeySet            JWTKeySet         // Resolves verification keys by kid, e.g. a JWKS
+	AllowedAlgorithms []string          // Accepted header algorithms (defaults to Algorithm)
+	Audience          []string          // Accepted audiences, also written into new tokens
+	RequiredClaims    []string          // Claims every token must carry
+	Leeway            time.Duration     // Clock skew tolerated for exp, nbf and iat
+	MaxAge            time.Duration     // Rejects tokens issued longer ago than this
+	RefreshExpiration time.Duration     // Lifetime of refresh tokens
+	RefreshStore      Store             // Tracks the current refresh token of each family
+	Revocations       RevocationStore   // Denylist checked by JWTAuth
 }
 
 // NewJWTConfig creates a new JWT configuration
 func NewJWTConfig(secret string) *JWTConfig {
 	return &JWTConfig{
-		Secret:     secret,
-		Issuer:     "velocity-framework",
-		Expiration: 24 * time.Hour,
-		Algorithm:  "HS256",
+		Secret:            secret,
+		Issuer:            "velocity-framework",
+		Expiration:        24 * time.Hour,
+		Algorithm:         "HS256",
+		RefreshExpiration: 7 * 24 * time.Hour,
+		RefreshStore:      NewMemoryStore(),
+		Revocations:       NewMemoryRevocationStore(),
 	}
 }
 
@@ -62,12 +86,14 @@ func (config *JWTConfig) GenerateToken(claims map[string]interface{}) (string, e
 	header := JWTHeader{
 		Algorithm: config.Algorithm,
 		Type:      "JWT",
+		KeyID:     config.KeyID,
 	}
 	
 	payload := JWTPayload{
 		Issuer:         config.Issuer,
 		IssuedAt:       now.Unix(),
 		ExpirationTime: now.Add(config.Expiration).Unix(),
+		Audience:       config.Audience,
 		Claims:         claims,
 	}
 	
@@ -80,6 +106,15 @@ func (config *JWTConfig) GenerateToken(claims map[string]interface{}) (string, e
 		payloadMap[key] = value
 	}
 	
+	// Every token gets an ID so it can be revoked
+	if _, ok := payloadMap["jti"]; !ok {
+		jti, err := randomToken(16)
+		if err != nil {
+			return "", err
+		}
+		payloadMap["jti"] = jti
+	}
+	
 	// Encode header
 	headerBytes, err := json.Marshal(header)
 	if err != nil {
@@ -96,52 +131,68 @@ func (config *JWTConfig) GenerateToken(claims map[string]interface{}) (string, e
 	
 	// Create signature
 	message := headerEncoded + "." + payloadEncoded
-	signature := config.createSignature(message)
+	signature, err := signJWT(config.Algorithm, config.signingKey(), []byte(message))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %v", err)
+	}
 	
-	return message + "." + signature, nil
+	return message + "." + base64.RawURLEncoding.EncodeToString(signature), nil
 }
 
 // ValidateToken validates a JWT token
 func (config *JWTConfig) ValidateToken(tokenString string) (*JWT, error) {
 	parts := strings.Split(tokenString, ".")
 	if len(parts) != 3 {
-		return nil, errors.New("invalid token format")
+		return nil, ErrTokenMalformed
 	}
 	
 	headerEncoded, payloadEncoded, signatureEncoded := parts[0], parts[1], parts[2]
 	
-	// Verify signature
-	message := headerEncoded + "." + payloadEncoded
-	expectedSignature := config.createSignature(message)
-	if !hmac.Equal([]byte(signatureEncoded), []byte(expectedSignature)) {
-		return nil, errors.New("invalid signature")
-	}
-	
 	// Decode header
 	headerBytes, err := base64.RawURLEncoding.DecodeString(headerEncoded)
 	if err != nil {
-		return nil, err
+		return nil, ErrTokenMalformed
 	}
 	var header JWTHeader
 	if err := json.Unmarshal(headerBytes, &header); err != nil {
-		return nil, err
+		return nil, ErrTokenMalformed
+	}
+	
+	// Only accept allowlisted algorithms, so a token cannot choose how it is verified
+	if !config.algorithmAllowed(header.Algorithm) {
+		return nil, fmt.Errorf("%w: algorithm %q not allowed", ErrTokenUnverifiable, header.Algorithm)
+	}
+	key, err := config.verificationKey(header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenUnverifiable, err)
+	}
+	
+	// Verify signature
+	signature, err := base64.RawURLEncoding.DecodeString(signatureEncoded)
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	message := headerEncoded + "." + payloadEncoded
+	if err := verifyJWT(header.Algorithm, key, []byte(message), signature); err != nil {
+		if errors.Is(err, ErrTokenSignatureInvalid) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrTokenUnverifiable, err)
 	}
 	
 	// Decode payload
 	payloadBytes, err := base64.RawURLEncoding.DecodeString(payloadEncoded)
 	if err != nil {
-		return nil, err
+		return nil, ErrTokenMalformed
 	}
 	var payloadMap map[string]interface{}
 	if err := json.Unmarshal(payloadBytes, &payloadMap); err != nil {
-		return nil, err
+		return nil, ErrTokenMalformed
 	}
 	
-	// Check expiration
-	if exp, ok := payloadMap["exp"].(float64); ok {
-		if time.Now().Unix() > int64(exp) {
-			return nil, errors.New("token expired")
-		}
+	// Check registered claims
+	if err := config.validateClaims(payloadMap); err != nil {
+		return nil, err
 	}
 	
 	// Create JWT struct
@@ -158,9 +209,7 @@ func (config *JWTConfig) ValidateToken(tokenString string) (*JWT, error) {
 	if sub, ok := payloadMap["sub"].(string); ok {
 		jwt.Payload.Subject = sub
 	}
-	if aud, ok := payloadMap["aud"].(string); ok {
-		jwt.Payload.Audience = aud
-	}
+	jwt.Payload.Audience, _ = audienceClaim(payloadMap)
 	if exp, ok := payloadMap["exp"].(float64); ok {
 		jwt.Payload.ExpirationTime = int64(exp)
 	}
@@ -186,71 +235,100 @@ func (config *JWTConfig) ValidateToken(tokenString string) (*JWT, error) {
 	return jwt, nil
 }
 
-// createSignature creates HMAC signature
-func (config *JWTConfig) createSignature(message string) string {
-	h := hmac.New(sha256.New, []byte(config.Secret))
-	h.Write([]byte(message))
-	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+// algorithmAllowed reports whether a header algorithm is accepted
+func (config *JWTConfig) algorithmAllowed(alg string) bool {
+	allowed := config.AllowedAlgorithms
+	if len(allowed) == 0 {
+		allowed = []string{config.Algorithm}
+	}
+	for _, a := range allowed {
+		if a == alg && alg != "none" {
+			return true
+		}
+	}
+	return false
+}
+
+// signingKey returns the key used to sign new tokens
+func (config *JWTConfig) signingKey() interface{} {
+	if strings.HasPrefix(config.Algorithm, "HS") {
+		return []byte(config.Secret)
+	}
+	return config.PrivateKey
+}
+
+// verificationKey selects the key for a token. HMAC tokens always use the
+// configured secret; asymmetric tokens use the key set (by kid) when one
+// is configured, otherwise the configured public key.
+func (config *JWTConfig) verificationKey(header JWTHeader) (interface{}, error) {
+	if strings.HasPrefix(header.Algorithm, "HS") {
+		return []byte(config.Secret), nil
+	}
+	if config.KeySet != nil {
+		return config.KeySet.Key(header.KeyID, header.Algorithm)
+	}
+	if config.PublicKey != nil {
+		return config.PublicKey, nil
+	}
+	if key := publicKeyOf(config.PrivateKey); key != nil {
+		return key, nil
+	}
+	return nil, errors.New("no verification key configured")
 }
 
 // JWT Authentication Middleware
 func JWTAuth(config *JWTConfig) MiddlewareFunc {
-	return func(c *Context) error {
-		// Get token from Authorization header
-		authHeader := c.Request.Header.Get("Authorization")
-		if authHeader == "" {
-			return c.JSON(401, map[string]string{"error": "Missing authorization header"})
-		}
-		
-		// Check Bearer prefix
-		if !strings.HasPrefix(authHeader, "Bearer ") {
-			return c.JSON(401, map[string]string{"error": "Invalid authorization header format"})
-		}
-		
-		// Extract token
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		
-		// Validate token
-		jwt, err := config.ValidateToken(tokenString)
-		if err != nil {
-			return c.JSON(401, map[string]string{"error": fmt.Sprintf("Invalid token: %v", err)})
-		}
-		
-		// Store JWT in context
-		c.Set("jwt", jwt)
-		c.Set("user_id", jwt.Payload.Subject)
-		
-		// Store custom claims
-		for key, value := range jwt.Payload.Claims {
-			c.Set(key, value)
-		}
-		
-		return c.Next()
-	}
+	return JWTAuthWithConfig(NewJWTAuthConfig(config))
 }
 
 // Optional JWT middleware (doesn't fail if token is missing)
 func JWTOptional(config *JWTConfig) MiddlewareFunc {
-	return func(c *Context) error {
-		authHeader := c.Request.Header.Get("Authorization")
-		if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			if jwt, err := config.ValidateToken(tokenString); err == nil {
-				c.Set("jwt", jwt)
-				c.Set("user_id", jwt.Payload.Subject)
-				for key, value := range jwt.Payload.Claims {
-					c.Set(key, value)
-				}
-			}
-		}
+	authConfig := NewJWTAuthConfig(config)
+	authConfig.Optional = true
+	authConfig.ErrorHandler = func(c *Context, err error) error {
 		return c.Next()
 	}
+	return JWTAuthWithConfig(authConfig)
+}
+
+// authenticate validates an access token and checks it has not been revoked
+func (config *JWTConfig) authenticate(c *Context, tokenString string) (*JWT, error) {
+	jwt, err := config.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if jwt.Payload.Claims[claimTokenUse] == "refresh" {
+		return nil, ErrInvalidTokenUse
+	}
+	if err := config.checkRevoked(c.Request.Context(), jwt); err != nil {
+		return nil, err
+	}
+	return jwt, nil
+}
+
+// jwtContextKey namespaces the validated token in the context locals.
+// Claims are read through GetJWT, GetClaim or ParseClaims rather than
+// being copied into locals, where they could overwrite other values.
+const jwtContextKey = "forge.jwt"
+
+// setJWT stores a validated token and fills the user ID slot
+func setJWT(c *Context, jwt *JWT) {
+	c.Set(jwtContextKey, jwt)
+	c.Set("user_id", jwt.Payload.Subject)
 }
 
 // Helper function to get JWT from context
 func GetJWT(c *Context) *JWT {
-	if jwt := c.Get("jwt"); jwt != nil {
-		return jwt.(*JWT)
+	if jwt, ok := c.Get(jwtContextKey).(*JWT); ok {
+		return jwt
+	}
+	return nil
+}
+
+// GetClaim returns a custom claim of the current token, or nil
+func GetClaim(c *Context, name string) interface{} {
+	if jwt := GetJWT(c); jwt != nil {
+		return jwt.Payload.Claims[name]
 	}
 	return nil
 }
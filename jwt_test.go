@@ -0,0 +1,389 @@
+package forge
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func generateTestKeys(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	return map[string]crypto.Signer{
+		AlgRS256: rsaKey,
+		AlgRS384: rsaKey,
+		AlgRS512: rsaKey,
+		AlgPS256: rsaKey,
+		AlgES256: p256,
+		AlgES384: p384,
+		AlgEdDSA: edKey,
+	}
+}
+
+func TestJWTAlgorithms(t *testing.T) {
+	for alg, key := range generateTestKeys(t) {
+		config := NewJWTConfig("")
+		config.Algorithm = alg
+		config.PrivateKey = key
+
+		token, err := config.GenerateToken(map[string]interface{}{"sub": "42"})
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		jwt, err := config.ValidateToken(token)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if jwt.Payload.Subject != "42" || jwt.Header.Algorithm != alg {
+			t.Errorf("%s: unexpected token %+v", alg, jwt)
+		}
+
+		// Flipping a signature byte must fail verification
+		parts := strings.Split(token, ".")
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		sig[0] ^= 0xff
+		tampered := parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(sig)
+		if _, err := config.ValidateToken(tampered); !errors.Is(err, ErrTokenSignatureInvalid) {
+			t.Errorf("%s: expected ErrTokenSignatureInvalid, got %v", alg, err)
+		}
+	}
+}
+
+// forgeToken builds a token with an arbitrary header, signed with HMAC
+func forgeToken(alg string, secret []byte) string {
+	header, _ := json.Marshal(JWTHeader{Algorithm: alg, Type: "JWT"})
+	payload, _ := json.Marshal(map[string]interface{}{"sub": "admin"})
+	message := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	if alg == "none" {
+		return message + "."
+	}
+	sig, _ := signJWT(alg, secret, []byte(message))
+	return message + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTAlgorithmAllowlist(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	config := NewJWTConfig("")
+	config.Algorithm = AlgRS256
+	config.PrivateKey = key
+
+	// Classic confusion attack: HMAC-sign with the public key bytes
+	jwk, _ := NewJWK("", AlgRS256, &key.PublicKey)
+	for _, token := range []string{forgeToken(AlgHS256, []byte(jwk.N)), forgeToken("none", nil)} {
+		if _, err := config.ValidateToken(token); !errors.Is(err, ErrTokenUnverifiable) {
+			t.Errorf("Expected ErrTokenUnverifiable, got %v", err)
+		}
+	}
+
+	// HMAC allowed explicitly but with an empty secret is still rejected
+	config.AllowedAlgorithms = []string{AlgRS256, AlgHS256}
+	if _, err := config.ValidateToken(forgeToken(AlgHS256, []byte(""))); err == nil {
+		t.Error("Expected empty HMAC secret to be rejected")
+	}
+}
+
+func writeJWKS(t *testing.T, keys map[string]crypto.Signer, alg string) []byte {
+	t.Helper()
+	set := JWKSet{}
+	for kid, key := range keys {
+		jwk, err := NewJWK(kid, alg, key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	data, _ := json.Marshal(set)
+	return data
+}
+
+func TestJWKSKeySelectionAndRotation(t *testing.T) {
+	key1, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	key2, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	var published atomic.Value
+	published.Store(writeJWKS(t, map[string]crypto.Signer{"k1": key1}, AlgES256))
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write(published.Load().([]byte))
+	}))
+	defer server.Close()
+
+	jwksConfig := NewJWKSConfig(server.URL)
+	jwksConfig.MinRefreshInterval = 0
+	verifier := NewJWTConfig("")
+	verifier.AllowedAlgorithms = []string{AlgES256}
+	verifier.KeySet = NewJWKS(jwksConfig)
+
+	issue := func(kid string, key crypto.Signer) string {
+		signer := NewJWTConfig("")
+		signer.Algorithm = AlgES256
+		signer.PrivateKey = key
+		signer.KeyID = kid
+		token, err := signer.GenerateToken(map[string]interface{}{"sub": kid})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	if _, err := verifier.ValidateToken(issue("k1", key1)); err != nil {
+		t.Fatalf("Expected k1 token to validate: %v", err)
+	}
+	if _, err := verifier.ValidateToken(issue("k1", key1)); err != nil || fetches.Load() != 1 {
+		t.Fatalf("Expected cached keys, got %v after %d fetches", err, fetches.Load())
+	}
+
+	// A token claiming k1 but signed with another key fails
+	if _, err := verifier.ValidateToken(issue("k1", key2)); !errors.Is(err, ErrTokenSignatureInvalid) {
+		t.Errorf("Expected signature failure, got %v", err)
+	}
+
+	// A new kid triggers a refresh after the issuer rotates
+	published.Store(writeJWKS(t, map[string]crypto.Signer{"k1": key1, "k2": key2}, AlgES256))
+	if _, err := verifier.ValidateToken(issue("k2", key2)); err != nil {
+		t.Errorf("Expected rotated key to be fetched: %v", err)
+	}
+	if _, err := verifier.ValidateToken(issue("k3", key2)); !errors.Is(err, ErrTokenUnverifiable) {
+		t.Errorf("Expected unknown kid to be unverifiable, got %v", err)
+	}
+}
+
+func TestJWKSRefreshDoesNotBlockCachedKeys(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	set := writeJWKS(t, map[string]crypto.Signer{"k1": key}, AlgES256)
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release // Every refresh after the first load hangs
+		}
+		w.Write(set)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	config := NewJWKSConfig(server.URL)
+	config.RefreshInterval = time.Millisecond
+	config.MinRefreshInterval = 0
+	jwks := NewJWKS(config)
+	if err := jwks.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Expired keys trigger a background refresh but are still served
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if _, err := jwks.Key("k1", AlgES256); err != nil {
+			t.Fatalf("Expected cached key during refresh: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected cached lookups not to wait for the endpoint, took %v", elapsed)
+	}
+
+	// Unknown kids wait for the running fetch instead of starting their own
+	results := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := jwks.Key("k9", AlgES256)
+			results <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("Expected a single in-flight refresh, got %d fetches", n)
+	}
+	release <- struct{}{}
+	for i := 0; i < 3; i++ {
+		if err := <-results; !errors.Is(err, ErrJWKNotFound) {
+			t.Errorf("Expected unknown kid after refresh, got %v", err)
+		}
+	}
+}
+
+func TestJWKSFileAndAlgorithmPinning(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, writeJWKS(t, map[string]crypto.Signer{"ed": edKey}, AlgEdDSA), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := NewJWKS(NewJWKSConfig(path))
+	if _, err := jwks.Key("ed", AlgEdDSA); err != nil {
+		t.Fatalf("Expected key from file: %v", err)
+	}
+	if _, err := jwks.Key("", AlgEdDSA); err != nil {
+		t.Errorf("Expected single key to be selected without kid: %v", err)
+	}
+	if _, err := jwks.Key("ed", AlgES256); err == nil {
+		t.Error("Expected key pinned to EdDSA to be refused for ES256")
+	}
+
+	missing := NewJWKS(NewJWKSConfig(filepath.Join(t.TempDir(), "missing.json")))
+	if _, err := missing.Key("ed", AlgEdDSA); err == nil || errors.Is(err, ErrJWKNotFound) {
+		t.Errorf("Expected load error, got %v", err)
+	}
+}
+
+func TestJWTRegisteredClaims(t *testing.T) {
+	config := NewJWTConfig("secret")
+	config.Audience = []string{"api"}
+	config.Leeway = 30 * time.Second
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		err    error
+	}{
+		{"valid", map[string]interface{}{}, nil},
+		{"audience array", map[string]interface{}{"aud": []string{"web", "api"}}, nil},
+		{"expired", map[string]interface{}{"exp": now - 60}, ErrTokenExpired},
+		{"expired within leeway", map[string]interface{}{"exp": now - 10}, nil},
+		{"not valid yet", map[string]interface{}{"nbf": now + 60}, ErrTokenNotValidYet},
+		{"issued in the future", map[string]interface{}{"iat": now + 60}, ErrTokenUsedBeforeIssued},
+		{"wrong issuer", map[string]interface{}{"iss": "someone-else"}, ErrInvalidIssuer},
+		{"wrong audience", map[string]interface{}{"aud": "admin"}, ErrInvalidAudience},
+		{"malformed exp", map[string]interface{}{"exp": "tomorrow"}, ErrTokenMalformed},
+	}
+
+	for _, tt := range tests {
+		token, err := config.GenerateToken(tt.claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jwt, err := config.ValidateToken(token)
+		if !errors.Is(err, tt.err) || (tt.err != nil && jwt != nil) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+		}
+	}
+
+	config.RequiredClaims = []string{"tenant"}
+	token, _ := config.GenerateToken(nil)
+	if _, err := config.ValidateToken(token); !errors.Is(err, ErrMissingClaim) {
+		t.Errorf("Expected ErrMissingClaim, got %v", err)
+	}
+}
+
+func TestJWTAuthChallenge(t *testing.T) {
+	config := NewJWTConfig("secret")
+	app := New()
+	app.Use(JWTAuth(config))
+	app.GET("/", func(c *Context) error { return c.String(200, GetUserID(c)) })
+
+	expired, _ := config.GenerateToken(map[string]interface{}{"exp": time.Now().Unix() - 60})
+	tests := []struct {
+		header    string
+		challenge string
+	}{
+		{"", `Bearer`},
+		{"Basic abc", `Bearer error="invalid_request", error_description="invalid authorization header format"`},
+		{"Bearer " + expired, `Bearer error="invalid_token", error_description="token expired"`},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != 401 || w.Header().Get("WWW-Authenticate") != tt.challenge {
+			t.Errorf("Expected 401 with %q, got %d %q", tt.challenge, w.Code, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+type testUserClaims struct {
+	RegisteredClaims
+	Role  string   `json:"role"`
+	Teams []string `json:"teams"`
+	Quota int64    `json:"quota"`
+}
+
+func TestTypedClaims(t *testing.T) {
+	config := NewJWTConfig("secret")
+	config.Audience = []string{"api"}
+
+	token, err := GenerateTokenFor(config, testUserClaims{
+		RegisteredClaims: RegisteredClaims{Subject: "42"},
+		Role:             "admin",
+		Teams:            []string{"core"},
+		Quota:            1 << 60,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ValidateTokenFor[testUserClaims](config, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "42" || claims.Role != "admin" || claims.Quota != 1<<60 {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+	if claims.Issuer != config.Issuer || claims.ExpiresAt == 0 || !claims.Audience.Contains("api") {
+		t.Errorf("Expected registered claims to be filled from config, got %+v", claims.RegisteredClaims)
+	}
+}
+
+func TestJWTAuthDoesNotSpreadClaims(t *testing.T) {
+	config := NewJWTConfig("secret")
+	app := New()
+	app.Use(func(c *Context) error {
+		c.Set("validator", "original")
+		return c.Next()
+	})
+	app.Use(JWTAuth(config))
+	app.GET("/", func(c *Context) error {
+		claims, err := ParseClaims[testUserClaims](c)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, map[string]interface{}{
+			"validator": c.Get("validator"),
+			"role":      claims.Role,
+			"claim":     GetClaim(c, "role"),
+			"user":      GetUserID(c),
+		})
+	})
+
+	token, _ := config.GenerateToken(map[string]interface{}{"sub": "7", "role": "editor", "validator": "hijacked"})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	var body map[string]string
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if body["validator"] != "original" {
+		t.Errorf("Claim overwrote a context local: %v", body)
+	}
+	if body["role"] != "editor" || body["claim"] != "editor" || body["user"] != "7" {
+		t.Errorf("Unexpected claims in context: %v", body)
+	}
+}
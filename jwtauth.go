@@ -0,0 +1,139 @@
+package forge
+
+import (
+	"errors"
+	"strings"
+)
+
+// JWTAuthConfig represents JWT authentication middleware configuration
+type JWTAuthConfig struct {
+	JWT *JWTConfig
+	// TokenLookup lists comma-separated sources tried in order:
+	// "header:<name>[:<prefix>]", "cookie:<name>" or "query:<name>".
+	// The Authorization header defaults to the "Bearer " prefix.
+	TokenLookup    string
+	Realm          string // realm sent in WWW-Authenticate
+	Optional       bool   // Continues without identity when no token is sent
+	Skipper        func(*Context) bool
+	SuccessHandler func(*Context, *JWT) error
+	ErrorHandler   func(*Context, error) error
+}
+
+// NewJWTAuthConfig creates a JWT auth configuration reading the Authorization header
+func NewJWTAuthConfig(jwt *JWTConfig) *JWTAuthConfig {
+	return &JWTAuthConfig{
+		JWT:         jwt,
+		TokenLookup: "header:Authorization",
+	}
+}
+
+// tokenExtractor reads a token from one source of the request
+type tokenExtractor func(*Context) (string, error)
+
+// JWTAuthWithConfig creates a JWT authentication middleware. Query and
+// cookie lookups allow browser WebSocket clients, which cannot set
+// headers, to authenticate before the upgrade.
+func JWTAuthWithConfig(config *JWTAuthConfig) MiddlewareFunc {
+	extractors := parseTokenLookup(config.TokenLookup)
+
+	errorHandler := config.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = func(c *Context, err error) error {
+			c.Header("WWW-Authenticate", bearerChallenge(config.Realm, err))
+			description := tokenErrorDescription(err)
+			if errors.Is(err, ErrTokenMissing) || errors.Is(err, ErrInvalidAuthHeader) {
+				description = err.Error()
+			}
+			return c.JSON(401, map[string]string{"error": description})
+		}
+	}
+
+	return func(c *Context) error {
+		if config.Skipper != nil && config.Skipper(c) {
+			return c.Next()
+		}
+
+		tokenString, err := extractToken(c, extractors)
+		if err == nil {
+			var jwt *JWT
+			if jwt, err = config.JWT.authenticate(c, tokenString); err == nil {
+				setJWT(c, jwt)
+				if config.SuccessHandler != nil {
+					if err := config.SuccessHandler(c, jwt); err != nil {
+						return err
+					}
+				}
+				return c.Next()
+			}
+		}
+
+		if config.Optional && errors.Is(err, ErrTokenMissing) {
+			return c.Next()
+		}
+		return errorHandler(c, err)
+	}
+}
+
+// extractToken returns the first token found. A malformed source is only
+// reported when no other source yields a token.
+func extractToken(c *Context, extractors []tokenExtractor) (string, error) {
+	var lastErr error = ErrTokenMissing
+	for _, extract := range extractors {
+		token, err := extract(c)
+		if token != "" {
+			return token, nil
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return "", lastErr
+}
+
+// parseTokenLookup builds extractors from a TokenLookup string. Unknown
+// sources are ignored, so a bad lookup fails closed with ErrTokenMissing.
+func parseTokenLookup(lookup string) []tokenExtractor {
+	extractors := make([]tokenExtractor, 0)
+	for _, source := range strings.Split(lookup, ",") {
+		parts := strings.SplitN(strings.TrimSpace(source), ":", 3)
+		if len(parts) < 2 || parts[1] == "" {
+			continue
+		}
+		name := parts[1]
+
+		switch parts[0] {
+		case "header":
+			prefix := ""
+			if len(parts) == 3 {
+				prefix = parts[2]
+			} else if strings.EqualFold(name, "Authorization") {
+				prefix = "Bearer "
+			}
+			extractors = append(extractors, func(c *Context) (string, error) {
+				value := c.Request.Header.Get(name)
+				if value == "" {
+					return "", nil
+				}
+				if prefix == "" {
+					return value, nil
+				}
+				if len(value) <= len(prefix) || !strings.EqualFold(value[:len(prefix)], prefix) {
+					return "", ErrInvalidAuthHeader
+				}
+				return value[len(prefix):], nil
+			})
+		case "cookie":
+			extractors = append(extractors, func(c *Context) (string, error) {
+				if cookie, err := c.Request.Cookie(name); err == nil {
+					return cookie.Value, nil
+				}
+				return "", nil
+			})
+		case "query":
+			extractors = append(extractors, func(c *Context) (string, error) {
+				return c.Request.URL.Query().Get(name), nil
+			})
+		}
+	}
+	return extractors
+}
@@ -0,0 +1,98 @@
+package forge
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJWTAuthTokenLookup(t *testing.T) {
+	jwtConfig := NewJWTConfig("secret")
+	token, _ := jwtConfig.GenerateToken(map[string]interface{}{"sub": "42"})
+
+	config := NewJWTAuthConfig(jwtConfig)
+	config.TokenLookup = "header:Authorization,header:X-API-Token,cookie:access_token,query:token"
+	config.Realm = "api"
+	app := New()
+	app.Use(JWTAuthWithConfig(config))
+	app.GET("/", func(c *Context) error { return c.String(200, GetUserID(c)) })
+
+	requests := map[string]func(*http.Request){
+		"header": func(r *http.Request) { r.Header.Set("Authorization", "bearer "+token) },
+		"custom": func(r *http.Request) { r.Header.Set("X-API-Token", token) },
+		"cookie": func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "access_token", Value: token}) },
+		"query":  func(r *http.Request) { r.URL.RawQuery = "token=" + token },
+	}
+	for name, prepare := range requests {
+		req := httptest.NewRequest("GET", "/", nil)
+		prepare(req)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != 200 || w.Body.String() != "42" {
+			t.Errorf("%s: expected token to be found, got %d %q", name, w.Code, w.Body.String())
+		}
+	}
+
+	// The raw validation error is not leaked to the client
+	req := httptest.NewRequest("GET", "/?token=not.a.token", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	challenge := w.Header().Get("WWW-Authenticate")
+	if w.Code != 401 || !strings.HasPrefix(challenge, `Bearer realm="api", error="invalid_token"`) {
+		t.Errorf("Expected invalid_token challenge, got %d %q", w.Code, challenge)
+	}
+	if strings.Contains(w.Body.String(), "invalid character") {
+		t.Errorf("Response leaks parser details: %s", w.Body.String())
+	}
+}
+
+func TestJWTAuthHandlers(t *testing.T) {
+	jwtConfig := NewJWTConfig("secret")
+	admin, _ := jwtConfig.GenerateToken(map[string]interface{}{"sub": "1", "role": "admin"})
+	user, _ := jwtConfig.GenerateToken(map[string]interface{}{"sub": "2", "role": "user"})
+
+	config := NewJWTAuthConfig(jwtConfig)
+	config.Skipper = func(c *Context) bool { return c.Request.URL.Path == "/public" }
+	config.SuccessHandler = func(c *Context, jwt *JWT) error {
+		if jwt.Payload.Claims["role"] != "admin" {
+			return c.JSON(403, map[string]string{"error": "admins only"})
+		}
+		return nil
+	}
+	var handled error
+	config.ErrorHandler = func(c *Context, err error) error {
+		handled = err
+		return c.String(418, "custom")
+	}
+
+	app := New()
+	app.Use(JWTAuthWithConfig(config))
+	app.GET("/public", func(c *Context) error { return c.String(200, "public") })
+	app.GET("/admin", func(c *Context) error { return c.String(200, "admin") })
+
+	tests := []struct {
+		path, token string
+		code        int
+	}{
+		{"/public", "", 200},
+		{"/admin", admin, 200},
+		{"/admin", user, 403},
+		{"/admin", "", 418},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.code, w.Code)
+		}
+	}
+	if !errors.Is(handled, ErrTokenMissing) {
+		t.Errorf("Expected ErrorHandler to receive ErrTokenMissing, got %v", handled)
+	}
+}
@@ -0,0 +1,263 @@
+package forge
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Registered-claims validation errors
+var (
+	ErrTokenExpired          = errors.New("token expired")
+	ErrTokenNotValidYet      = errors.New("token not valid yet")
+	ErrTokenUsedBeforeIssued = errors.New("token used before issued")
+	ErrTokenTooOld           = errors.New("token too old")
+	ErrInvalidIssuer         = errors.New("invalid issuer")
+	ErrInvalidAudience       = errors.New("invalid audience")
+	ErrMissingClaim          = errors.New("missing required claim")
+)
+
+// JWTAudience holds the "aud" claim, which may be a string or an array.
+// A single audience is encoded as a string.
+type JWTAudience []string
+
+// MarshalJSON encodes a single audience as a string
+func (a JWTAudience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON accepts a string or an array of strings
+func (a *JWTAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = JWTAudience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return ErrTokenMalformed
+	}
+	*a = many
+	return nil
+}
+
+// Contains reports whether the audience includes any of the given values
+func (a JWTAudience) Contains(values ...string) bool {
+	for _, aud := range a {
+		for _, value := range values {
+			if aud == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateClaims checks the registered claims of a decoded payload
+func (config *JWTConfig) validateClaims(claims map[string]interface{}) error {
+	now := time.Now()
+	leeway := config.Leeway
+
+	for _, name := range config.RequiredClaims {
+		if _, ok := claims[name]; !ok {
+			return fmt.Errorf("%w: %s", ErrMissingClaim, name)
+		}
+	}
+
+	exp, hasExp, err := numericClaim(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if hasExp && now.After(exp.Add(leeway)) {
+		return ErrTokenExpired
+	}
+
+	nbf, hasNbf, err := numericClaim(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	if hasNbf && now.Add(leeway).Before(nbf) {
+		return ErrTokenNotValidYet
+	}
+
+	iat, hasIat, err := numericClaim(claims, "iat")
+	if err != nil {
+		return err
+	}
+	if hasIat {
+		if now.Add(leeway).Before(iat) {
+			return ErrTokenUsedBeforeIssued
+		}
+		if config.MaxAge > 0 && now.After(iat.Add(config.MaxAge+leeway)) {
+			return ErrTokenTooOld
+		}
+	} else if config.MaxAge > 0 {
+		return fmt.Errorf("%w: iat", ErrMissingClaim)
+	}
+
+	if config.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != config.Issuer {
+			return ErrInvalidIssuer
+		}
+	}
+
+	if len(config.Audience) > 0 {
+		aud, err := audienceClaim(claims)
+		if err != nil {
+			return err
+		}
+		if !aud.Contains(config.Audience...) {
+			return ErrInvalidAudience
+		}
+	}
+	return nil
+}
+
+// numericClaim reads a NumericDate claim
+func numericClaim(claims map[string]interface{}, name string) (time.Time, bool, error) {
+	value, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	seconds, ok := value.(float64)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%w: %s is not a number", ErrTokenMalformed, name)
+	}
+	return time.Unix(int64(seconds), 0), true, nil
+}
+
+// audienceClaim reads the "aud" claim as a string or array
+func audienceClaim(claims map[string]interface{}) (JWTAudience, error) {
+	switch aud := claims["aud"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return JWTAudience{aud}, nil
+	case []interface{}:
+		audience := make(JWTAudience, 0, len(aud))
+		for _, v := range aud {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: aud", ErrTokenMalformed)
+			}
+			audience = append(audience, s)
+		}
+		return audience, nil
+	}
+	return nil, fmt.Errorf("%w: aud", ErrTokenMalformed)
+}
+
+// tokenErrors lists the errors whose messages are safe to send to clients
+var tokenErrors = []error{
+	ErrTokenExpired, ErrTokenNotValidYet, ErrTokenUsedBeforeIssued, ErrTokenTooOld,
+	ErrInvalidIssuer, ErrInvalidAudience, ErrMissingClaim,
+	ErrTokenMalformed, ErrTokenUnverifiable, ErrTokenSignatureInvalid,
+	ErrTokenRevoked, ErrRefreshTokenReused, ErrInvalidTokenUse,
+}
+
+// tokenErrorDescription returns a client-safe description of a token error
+func tokenErrorDescription(err error) string {
+	for _, known := range tokenErrors {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	return "invalid token"
+}
+
+// bearerChallenge builds a WWW-Authenticate value (RFC 6750). A missing
+// token gets a bare challenge; a malformed header is invalid_request and
+// any validation failure is invalid_token.
+func bearerChallenge(realm string, err error) string {
+	params := make([]string, 0, 3)
+	if realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", realm))
+	}
+	switch {
+	case err == nil || errors.Is(err, ErrTokenMissing):
+	case errors.Is(err, ErrInvalidAuthHeader):
+		params = append(params, `error="invalid_request"`, fmt.Sprintf("error_description=%q", err.Error()))
+	default:
+		params = append(params, `error="invalid_token"`, fmt.Sprintf("error_description=%q", tokenErrorDescription(err)))
+	}
+	if len(params) == 0 {
+		return "Bearer"
+	}
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+// RegisteredClaims holds the registered JWT claims. Embed it in a struct
+// to use typed claims with GenerateTokenFor and ParseClaims:
+//
+//	type UserClaims struct {
+//		forge.RegisteredClaims
+//		Role string `json:"role"`
+//	}
+type RegisteredClaims struct {
+	Issuer    string      `json:"iss,omitempty"`
+	Subject   string      `json:"sub,omitempty"`
+	Audience  JWTAudience `json:"aud,omitempty"`
+	ExpiresAt int64       `json:"exp,omitempty"`
+	NotBefore int64       `json:"nbf,omitempty"`
+	IssuedAt  int64       `json:"iat,omitempty"`
+	ID        string      `json:"jti,omitempty"`
+}
+
+// GenerateTokenFor signs a token from a claims struct. Registered claims
+// left empty are filled from the config as in GenerateToken.
+func GenerateTokenFor[T any](config *JWTConfig, claims T) (string, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var payload map[string]interface{}
+	if err := decoder.Decode(&payload); err != nil || payload == nil {
+		return "", errors.New("claims must encode to a JSON object")
+	}
+	return config.GenerateToken(payload)
+}
+
+// ValidateTokenFor validates a token and decodes its claims into T
+func ValidateTokenFor[T any](config *JWTConfig, tokenString string) (T, error) {
+	var claims T
+	jwt, err := config.ValidateToken(tokenString)
+	if err != nil {
+		return claims, err
+	}
+	err = jwt.DecodeClaims(&claims)
+	return claims, err
+}
+
+// ParseClaims decodes the claims of the token validated by JWTAuth into T
+func ParseClaims[T any](c *Context) (T, error) {
+	var claims T
+	jwt := GetJWT(c)
+	if jwt == nil {
+		return claims, ErrTokenMissing
+	}
+	err := jwt.DecodeClaims(&claims)
+	return claims, err
+}
+
+// DecodeClaims unmarshals the token payload into v
+func (j *JWT) DecodeClaims(v interface{}) error {
+	parts := strings.Split(j.Raw, ".")
+	if len(parts) != 3 {
+		return ErrTokenMalformed
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ErrTokenMalformed
+	}
+	return json.Unmarshal(payload, v)
+}
@@ -0,0 +1,260 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// Refresh token errors
+var (
+	ErrTokenRevoked       = errors.New("token revoked")
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+	ErrInvalidTokenUse    = errors.New("token cannot be used here")
+)
+
+// Claims used internally by refresh token families
+const (
+	claimTokenUse = "token_use"
+	claimFamily   = "fam"
+)
+
+// TokenPair is an access token with the refresh token used to renew it
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// IssueTokenPair starts a new token family and returns its first pair.
+// Every token of the family carries the given claims.
+func (config *JWTConfig) IssueTokenPair(ctx context.Context, claims map[string]interface{}) (*TokenPair, error) {
+	if config.RefreshStore == nil {
+		return nil, errors.New("refresh tokens require a RefreshStore")
+	}
+	family, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	refreshID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.RefreshStore.Set(ctx, refreshKey(family), []byte(refreshID), config.RefreshExpiration); err != nil {
+		return nil, err
+	}
+	return config.tokenPair(claims, family, refreshID)
+}
+
+// Refresh exchanges a refresh token for a new pair. The presented token is
+// rotated out; presenting it again is treated as theft and revokes every
+// token of the family, including access tokens already issued.
+func (config *JWTConfig) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	if config.RefreshStore == nil {
+		return nil, errors.New("refresh tokens require a RefreshStore")
+	}
+	jwt, err := config.ValidateToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	family, _ := jwt.Payload.Claims[claimFamily].(string)
+	if jwt.Payload.Claims[claimTokenUse] != "refresh" || family == "" || jwt.Payload.JWTID == "" {
+		return nil, ErrInvalidTokenUse
+	}
+	if err := config.checkRevoked(ctx, jwt); err != nil {
+		return nil, err
+	}
+
+	next, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	err = config.RefreshStore.Update(ctx, refreshKey(family), config.RefreshExpiration, func(current []byte) ([]byte, error) {
+		if current == nil {
+			return nil, ErrTokenRevoked
+		}
+		if string(current) != jwt.Payload.JWTID {
+			return nil, ErrRefreshTokenReused
+		}
+		return []byte(next), nil
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if revokeErr := config.RevokeFamily(ctx, family); revokeErr != nil {
+			return nil, revokeErr
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{}, len(jwt.Payload.Claims)+1)
+	for key, value := range jwt.Payload.Claims {
+		if key != claimTokenUse && key != claimFamily {
+			claims[key] = value
+		}
+	}
+	if jwt.Payload.Subject != "" {
+		claims["sub"] = jwt.Payload.Subject
+	}
+	return config.tokenPair(claims, family, next)
+}
+
+// Revoke denies a single token until it expires
+func (config *JWTConfig) Revoke(ctx context.Context, jwt *JWT) error {
+	if config.Revocations == nil {
+		return errors.New("revocation requires a RevocationStore")
+	}
+	if jwt.Payload.JWTID == "" {
+		return errors.New("token has no jti")
+	}
+	return config.Revocations.Revoke(ctx, jwt.Payload.JWTID, time.Unix(jwt.Payload.ExpirationTime, 0))
+}
+
+// RevokeFamily denies every access and refresh token of a family
+func (config *JWTConfig) RevokeFamily(ctx context.Context, family string) error {
+	if config.Revocations == nil {
+		return errors.New("revocation requires a RevocationStore")
+	}
+	// Outstanding tokens were issued before now, so none outlives the
+	// longer of the two lifetimes
+	lifetime := config.Expiration
+	if config.RefreshExpiration > lifetime {
+		lifetime = config.RefreshExpiration
+	}
+	if lifetime <= 0 {
+		lifetime = 7 * 24 * time.Hour
+	}
+	until := time.Now().Add(lifetime)
+	if err := config.Revocations.Revoke(ctx, familyKey(family), until); err != nil {
+		return err
+	}
+	if config.RefreshStore != nil {
+		return config.RefreshStore.Delete(ctx, refreshKey(family))
+	}
+	return nil
+}
+
+// RefreshHandler returns a handler that exchanges {"refresh_token": "..."}
+// for a new token pair
+func RefreshHandler(config *JWTConfig) HandlerFunc {
+	return func(c *Context) error {
+		refreshToken := readRefreshToken(c)
+		if refreshToken == "" {
+			c.Header("WWW-Authenticate", bearerChallenge("", ErrTokenMissing))
+			return c.JSON(400, map[string]string{"error": "Missing refresh token"})
+		}
+
+		pair, err := config.Refresh(c.Request.Context(), refreshToken)
+		if err != nil {
+			c.Header("WWW-Authenticate", bearerChallenge("", err))
+			return c.JSON(401, map[string]string{"error": "Invalid refresh token: " + tokenErrorDescription(err)})
+		}
+		c.Header("Cache-Control", "no-store")
+		return c.JSON(200, pair)
+	}
+}
+
+// Logout returns a handler that revokes the caller's token family. It uses
+// the token validated by JWTAuth and an optional {"refresh_token": "..."} body.
+func Logout(config *JWTConfig) HandlerFunc {
+	return func(c *Context) error {
+		ctx := c.Request.Context()
+
+		if jwt := GetJWT(c); jwt != nil {
+			if err := config.revokeToken(ctx, jwt); err != nil {
+				return err
+			}
+		}
+		if refreshToken := readRefreshToken(c); refreshToken != "" {
+			if jwt, err := config.ValidateToken(refreshToken); err == nil {
+				if err := config.revokeToken(ctx, jwt); err != nil {
+					return err
+				}
+			}
+		}
+
+		c.Response.WriteHeader(204)
+		return nil
+	}
+}
+
+// revokeToken revokes a token together with its family, if any
+func (config *JWTConfig) revokeToken(ctx context.Context, jwt *JWT) error {
+	if family, ok := jwt.Payload.Claims[claimFamily].(string); ok && family != "" {
+		return config.RevokeFamily(ctx, family)
+	}
+	return config.Revoke(ctx, jwt)
+}
+
+// checkRevoked checks a token and its family against the denylist
+func (config *JWTConfig) checkRevoked(ctx context.Context, jwt *JWT) error {
+	if config.Revocations == nil {
+		return nil
+	}
+	ids := []string{jwt.Payload.JWTID}
+	if family, ok := jwt.Payload.Claims[claimFamily].(string); ok && family != "" {
+		ids = append(ids, familyKey(family))
+	}
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		revoked, err := config.Revocations.IsRevoked(ctx, id)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+	return nil
+}
+
+// tokenPair signs an access and a refresh token for a family
+func (config *JWTConfig) tokenPair(claims map[string]interface{}, family, refreshID string) (*TokenPair, error) {
+	access := make(map[string]interface{}, len(claims)+1)
+	refresh := make(map[string]interface{}, len(claims)+4)
+	for key, value := range claims {
+		access[key] = value
+		refresh[key] = value
+	}
+	access[claimFamily] = family
+	refresh[claimFamily] = family
+	refresh[claimTokenUse] = "refresh"
+	refresh["jti"] = refreshID
+	refresh["exp"] = time.Now().Add(config.RefreshExpiration).Unix()
+
+	accessToken, err := config.GenerateToken(access)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := config.GenerateToken(refresh)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(config.Expiration.Seconds()),
+	}, nil
+}
+
+// readRefreshToken reads the refresh token from a JSON body
+func readRefreshToken(c *Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	json.NewDecoder(io.LimitReader(c.Request.Body, 1<<16)).Decode(&body)
+	return body.RefreshToken
+}
+
+func refreshKey(family string) string { return "refresh:" + family }
+func familyKey(family string) string  { return "family:" + family }
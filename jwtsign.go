@@ -0,0 +1,207 @@
+package forge
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Supported JWT signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgHS384 = "HS384"
+	AlgHS512 = "HS512"
+	AlgRS256 = "RS256"
+	AlgRS384 = "RS384"
+	AlgRS512 = "RS512"
+	AlgPS256 = "PS256"
+	AlgPS384 = "PS384"
+	AlgPS512 = "PS512"
+	AlgES256 = "ES256"
+	AlgES384 = "ES384"
+	AlgES512 = "ES512"
+	AlgEdDSA = "EdDSA"
+)
+
+// errKeyType is returned when a key does not match the algorithm family,
+// which also prevents algorithm confusion (e.g. an RSA public key used as
+// an HMAC secret)
+var errKeyType = errors.New("key type does not match algorithm")
+
+// jwtHash returns the hash used by an algorithm
+func jwtHash(alg string) (crypto.Hash, error) {
+	if alg == AlgEdDSA {
+		return 0, nil
+	}
+	if len(alg) != 5 {
+		return 0, fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+	switch alg[2:] {
+	case "256":
+		return crypto.SHA256, nil
+	case "384":
+		return crypto.SHA384, nil
+	case "512":
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported algorithm: %s", alg)
+}
+
+// jwtCurve returns the curve required by an ES algorithm
+func jwtCurve(alg string) elliptic.Curve {
+	switch alg {
+	case AlgES256:
+		return elliptic.P256()
+	case AlgES384:
+		return elliptic.P384()
+	case AlgES512:
+		return elliptic.P521()
+	}
+	return nil
+}
+
+// signJWT signs message with key using alg
+func signJWT(alg string, key interface{}, message []byte) ([]byte, error) {
+	hash, err := jwtHash(alg)
+	if err != nil {
+		return nil, err
+	}
+	var digest []byte
+	if hash != 0 && alg[0] != 'H' {
+		h := hash.New()
+		h.Write(message)
+		digest = h.Sum(nil)
+	}
+
+	switch alg[0] {
+	case 'H':
+		secret, ok := key.([]byte)
+		if !ok || len(secret) == 0 {
+			return nil, errKeyType
+		}
+		h := hmac.New(hash.New, secret)
+		h.Write(message)
+		return h.Sum(nil), nil
+
+	case 'R', 'P':
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errKeyType
+		}
+		if alg[0] == 'R' {
+			return rsa.SignPKCS1v15(rand.Reader, priv, hash, digest)
+		}
+		return rsa.SignPSS(rand.Reader, priv, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+
+	case 'E':
+		if alg == AlgEdDSA {
+			priv, ok := key.(ed25519.PrivateKey)
+			if !ok {
+				return nil, errKeyType
+			}
+			return ed25519.Sign(priv, message), nil
+		}
+		priv, ok := key.(*ecdsa.PrivateKey)
+		if !ok || priv.Curve != jwtCurve(alg) {
+			return nil, errKeyType
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, priv, digest)
+		if err != nil {
+			return nil, err
+		}
+		// JWS uses fixed-size big-endian R || S rather than ASN.1
+		size := (priv.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+		return signature, nil
+	}
+	return nil, fmt.Errorf("unsupported algorithm: %s", alg)
+}
+
+// verifyJWT checks a signature over message with key using alg
+func verifyJWT(alg string, key interface{}, message, signature []byte) error {
+	hash, err := jwtHash(alg)
+	if err != nil {
+		return err
+	}
+	var digest []byte
+	if hash != 0 && alg[0] != 'H' {
+		h := hash.New()
+		h.Write(message)
+		digest = h.Sum(nil)
+	}
+
+	switch alg[0] {
+	case 'H':
+		secret, ok := key.([]byte)
+		if !ok || len(secret) == 0 {
+			return errKeyType
+		}
+		h := hmac.New(hash.New, secret)
+		h.Write(message)
+		if !hmac.Equal(signature, h.Sum(nil)) {
+			return ErrTokenSignatureInvalid
+		}
+		return nil
+
+	case 'R', 'P':
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errKeyType
+		}
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		} else {
+			err = rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		if err != nil {
+			return ErrTokenSignatureInvalid
+		}
+		return nil
+
+	case 'E':
+		if alg == AlgEdDSA {
+			pub, ok := key.(ed25519.PublicKey)
+			if !ok {
+				return errKeyType
+			}
+			if !ed25519.Verify(pub, message, signature) {
+				return ErrTokenSignatureInvalid
+			}
+			return nil
+		}
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != jwtCurve(alg) {
+			return errKeyType
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return ErrTokenSignatureInvalid
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return ErrTokenSignatureInvalid
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm: %s", alg)
+}
+
+// publicKeyOf returns the public half of an asymmetric private key
+func publicKeyOf(key crypto.PrivateKey) crypto.PublicKey {
+	if signer, ok := key.(crypto.Signer); ok {
+		return signer.Public()
+	}
+	return nil
+}
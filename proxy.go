@@ -0,0 +1,251 @@
+package forge
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// SetTrustedProxies sets the proxies whose forwarding headers are trusted.
+// Entries may be CIDR ranges ("10.0.0.0/8") or single addresses ("127.0.0.1").
+// With no trusted proxies, forwarding headers are ignored entirely.
+func (f *Forge) SetTrustedProxies(proxies ...string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy address: %s", proxy)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy range: %s", proxy)
+		}
+		nets = append(nets, ipNet)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.trustedProxies = nets
+	return nil
+}
+
+// isTrustedProxy checks whether ip belongs to a trusted proxy range
+func (f *Forge) isTrustedProxy(ip net.IP) bool {
+	if f == nil || ip == nil {
+		return false
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, ipNet := range f.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIP returns the client IP address. Forwarding headers (Forwarded,
+// X-Forwarded-For and X-Real-IP, in that order) are only honored when the
+// request comes from a trusted proxy, and the chain is walked from the
+// nearest hop so that spoofed entries added by the client are skipped.
+func (c *Context) RealIP() string {
+	remote := remoteHost(c.Request.RemoteAddr)
+	if !c.forge.isTrustedProxy(net.ParseIP(remote)) {
+		return remote
+	}
+
+	if elements := parseForwarded(c.Request.Header.Values("Forwarded")); len(elements) > 0 {
+		if element := c.forwardedClient(elements); element != nil {
+			return element["for"]
+		}
+	}
+
+	if xff := c.Request.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := make([]string, 0)
+		for _, value := range xff {
+			for _, hop := range strings.Split(value, ",") {
+				hops = append(hops, stripPort(strings.TrimSpace(hop)))
+			}
+		}
+		if ip := c.firstUntrusted(hops); ip != "" {
+			return ip
+		}
+	}
+
+	if realIP := strings.TrimSpace(c.Request.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+
+	return remote
+}
+
+// Scheme returns the scheme ("http" or "https") the client used. Forwarded
+// proto and X-Forwarded-Proto are only honored from trusted proxies.
+func (c *Context) Scheme() string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+
+	if !c.forge.isTrustedProxy(net.ParseIP(remoteHost(c.Request.RemoteAddr))) {
+		return scheme
+	}
+
+	if element := c.forwardedClient(parseForwarded(c.Request.Header.Values("Forwarded"))); element != nil {
+		if proto := strings.ToLower(element["proto"]); proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+
+	if proto := strings.ToLower(lastHeaderValue(c.Request.Header, "X-Forwarded-Proto")); proto == "http" || proto == "https" {
+		return proto
+	}
+
+	return scheme
+}
+
+// Host returns the host the client requested. Forwarded host and
+// X-Forwarded-Host are only honored from trusted proxies.
+func (c *Context) Host() string {
+	if !c.forge.isTrustedProxy(net.ParseIP(remoteHost(c.Request.RemoteAddr))) {
+		return c.Request.Host
+	}
+
+	if element := c.forwardedClient(parseForwarded(c.Request.Header.Values("Forwarded"))); element != nil {
+		if host := element["host"]; host != "" {
+			return host
+		}
+	}
+
+	if host := lastHeaderValue(c.Request.Header, "X-Forwarded-Host"); host != "" {
+		return host
+	}
+
+	return c.Request.Host
+}
+
+// Redirect sends a redirect response. Relative locations are resolved
+// against the client-facing scheme and host.
+func (c *Context) Redirect(status int, location string) error {
+	if strings.HasPrefix(location, "/") && !strings.HasPrefix(location, "//") {
+		location = c.Scheme() + "://" + c.Host() + location
+	}
+	http.Redirect(c.Response, c.Request, location, status)
+	return nil
+}
+
+// forwardedClient returns the Forwarded element written by the first
+// trusted proxy, walking from the nearest hop
+func (c *Context) forwardedClient(elements []map[string]string) map[string]string {
+	for i := len(elements) - 1; i >= 0; i-- {
+		ip := net.ParseIP(elements[i]["for"])
+		if ip == nil {
+			// Obfuscated or unknown identifiers cannot be verified
+			return nil
+		}
+		if i == 0 || !c.forge.isTrustedProxy(ip) {
+			return elements[i]
+		}
+	}
+	return nil
+}
+
+// firstUntrusted walks hops from the nearest and returns the first address
+// not belonging to a trusted proxy
+func (c *Context) firstUntrusted(hops []string) string {
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			return ""
+		}
+		if i == 0 || !c.forge.isTrustedProxy(ip) {
+			return hops[i]
+		}
+	}
+	return ""
+}
+
+// parseForwarded parses RFC 7239 Forwarded header values into elements
+func parseForwarded(values []string) []map[string]string {
+	elements := make([]map[string]string, 0)
+	for _, value := range values {
+		for _, part := range splitQuoted(value, ',') {
+			element := make(map[string]string)
+			for _, pair := range splitQuoted(part, ';') {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				val = strings.Trim(strings.TrimSpace(val), `"`)
+				key = strings.ToLower(strings.TrimSpace(key))
+				if key == "for" {
+					val = stripPort(val)
+				}
+				element[key] = val
+			}
+			if len(element) > 0 {
+				elements = append(elements, element)
+			}
+		}
+	}
+	return elements
+}
+
+// splitQuoted splits s on sep, ignoring separators inside quoted strings
+func splitQuoted(s string, sep byte) []string {
+	parts := make([]string, 0)
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case sep:
+			if !inQuotes {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// stripPort removes an optional port and IPv6 brackets from an address
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
+
+// remoteHost returns the host part of a RemoteAddr
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// lastHeaderValue returns the last comma-separated value of a header,
+// i.e. the one added by the nearest proxy
+func lastHeaderValue(h http.Header, key string) string {
+	values := h.Values(key)
+	if len(values) == 0 {
+		return ""
+	}
+	parts := strings.Split(values[len(values)-1], ",")
+	return strings.TrimSpace(parts[len(parts)-1])
+}
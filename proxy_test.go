@@ -0,0 +1,78 @@
+package forge
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	app := New()
+	if err := app.SetTrustedProxies("10.0.0.0/8", "192.168.1.1"); err != nil {
+		t.Fatalf("SetTrustedProxies failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"untrusted ignores headers", "203.0.113.9:1234", map[string]string{"X-Forwarded-For": "1.1.1.1"}, "203.0.113.9"},
+		{"trusted uses X-Forwarded-For", "10.0.0.5:1234", map[string]string{"X-Forwarded-For": "198.51.100.7"}, "198.51.100.7"},
+		{"spoofed entries are skipped", "10.0.0.5:1234", map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.7, 10.0.0.9"}, "198.51.100.7"},
+		{"X-Real-IP", "192.168.1.1:80", map[string]string{"X-Real-IP": "198.51.100.8"}, "198.51.100.8"},
+		{"Forwarded", "10.0.0.5:1234", map[string]string{"Forwarded": `for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.7`}, "2001:db8:cafe::17"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		for key, value := range tt.headers {
+			req.Header.Set(key, value)
+		}
+		c := &Context{Request: req, forge: app}
+
+		if got := c.RealIP(); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSchemeAndHost(t *testing.T) {
+	app := New()
+	app.SetTrustedProxies("127.0.0.1")
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "127.0.0.1:5000"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "example.com")
+	c := &Context{Request: req, forge: app}
+
+	if c.Scheme() != "https" || c.Host() != "example.com" {
+		t.Errorf("Expected https://example.com, got %s://%s", c.Scheme(), c.Host())
+	}
+
+	req.RemoteAddr = "203.0.113.9:5000"
+	if c.Scheme() != "http" || c.Host() != req.Host {
+		t.Errorf("Untrusted proxy headers must be ignored, got %s://%s", c.Scheme(), c.Host())
+	}
+}
+
+func TestRedirectUsesForwardedScheme(t *testing.T) {
+	app := New()
+	app.SetTrustedProxies("127.0.0.1")
+	app.GET("/old", func(c *Context) error {
+		return c.Redirect(302, "/new")
+	})
+
+	req := httptest.NewRequest("GET", "/old", nil)
+	req.RemoteAddr = "127.0.0.1:5000"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "example.com")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if location := w.Header().Get("Location"); location != "https://example.com/new" {
+		t.Errorf("Expected https://example.com/new, got %s", location)
+	}
+}
@@ -0,0 +1,424 @@
+package forge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimitAlgorithm selects how requests are counted
+type RateLimitAlgorithm string
+
+// Supported rate limiting algorithms
+const (
+	FixedWindow RateLimitAlgorithm = "fixed_window"
+	TokenBucket RateLimitAlgorithm = "token_bucket"
+	SlidingLog  RateLimitAlgorithm = "sliding_log"
+	GCRA        RateLimitAlgorithm = "gcra"
+)
+
+// RateLimit represents a single limit of Requests per Window
+type RateLimit struct {
+	Requests int           // Requests allowed per window
+	Window   time.Duration // Window length
+	Burst    int           // Burst size for TokenBucket and GCRA (defaults to Requests)
+}
+
+// RateLimitKeyFunc returns the key a request is counted under.
+// Returning an empty key skips rate limiting for the request.
+type RateLimitKeyFunc func(*Context) string
+
+// RateLimiterConfig represents rate limiter configuration
+type RateLimiterConfig struct {
+	RateLimit                                       // Default limit
+	Algorithm RateLimitAlgorithm                    // Counting algorithm
+	KeyFunc   RateLimitKeyFunc                      // Key extractor (defaults to KeyByIP)
+	Routes    map[string]RateLimit                  // Per-route limits keyed by pattern ("/login") or method and pattern ("POST /login")
+	Store     Store                                 // Counter storage (defaults to a MemoryStore)
+	Prefix    string                                // Prefix for store keys
+	Skipper   func(*Context) bool                   // Skips rate limiting when it returns true
+	OnLimit   func(*Context, RateLimitResult) error // Called when the limit is exceeded
+}
+
+// RateLimitResult represents the outcome of a rate limit check
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // Time until the limit is fully replenished
+	RetryAfter time.Duration // Time until the next request is allowed
+}
+
+// NewRateLimiterConfig creates a new rate limiter configuration
+func NewRateLimiterConfig(requests int, window time.Duration) *RateLimiterConfig {
+	return &RateLimiterConfig{
+		RateLimit: RateLimit{Requests: requests, Window: window},
+		Algorithm: TokenBucket,
+		KeyFunc:   KeyByIP,
+		Routes:    make(map[string]RateLimit),
+		Prefix:    "ratelimit:",
+	}
+}
+
+// RateLimiter limits each client IP to requests per fixed window
+func RateLimiter(requests int, window time.Duration) MiddlewareFunc {
+	config := NewRateLimiterConfig(requests, window)
+	config.Algorithm = FixedWindow
+	return RateLimiterWithConfig(config)
+}
+
+// RateLimiterWithConfig creates a rate limiting middleware. It panics with
+// the validation error when the configuration is invalid, so a misconfigured
+// limiter fails at startup instead of on every request.
+func RateLimiterWithConfig(config *RateLimiterConfig) MiddlewareFunc {
+	if err := config.validate(); err != nil {
+		panic(fmt.Sprintf("rate limiter: %v", err))
+	}
+
+	store := config.Store
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	keyFunc := config.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByIP
+	}
+	onLimit := config.OnLimit
+	if onLimit == nil {
+		onLimit = func(c *Context, _ RateLimitResult) error {
+			return c.String(http.StatusTooManyRequests, "Rate limit exceeded")
+		}
+	}
+
+	return func(c *Context) error {
+		if config.Skipper != nil && config.Skipper(c) {
+			return c.Next()
+		}
+
+		key := keyFunc(c)
+		if key == "" {
+			return c.Next()
+		}
+
+		limit := config.RateLimit
+		if routeLimit, scope, ok := config.routeLimit(c); ok {
+			limit = routeLimit
+			key = scope + "|" + key
+		}
+
+		result, err := takeRateLimit(c, store, config.Prefix+key, limit, config.Algorithm)
+		if err != nil {
+			return fmt.Errorf("rate limiter store: %v", err)
+		}
+
+		setRateLimitHeaders(c, limit, result)
+		if !result.Allowed {
+			return onLimit(c, result)
+		}
+
+		return c.Next()
+	}
+}
+
+// validate checks the default limit, every route limit and the algorithm
+func (config *RateLimiterConfig) validate() error {
+	if err := config.RateLimit.validate(); err != nil {
+		return err
+	}
+	for route, limit := range config.Routes {
+		if err := limit.validate(); err != nil {
+			return fmt.Errorf("route %q: %v", route, err)
+		}
+	}
+	switch config.Algorithm {
+	case FixedWindow, TokenBucket, SlidingLog, GCRA, "":
+		return nil
+	}
+	return fmt.Errorf("unknown rate limit algorithm %q", config.Algorithm)
+}
+
+// validate rejects limits the algorithms cannot divide by
+func (l RateLimit) validate() error {
+	switch {
+	case l.Requests <= 0:
+		return fmt.Errorf("requests must be positive, got %d", l.Requests)
+	case l.Window <= 0:
+		return fmt.Errorf("window must be positive, got %v", l.Window)
+	case l.Window/time.Duration(l.Requests) == 0:
+		return fmt.Errorf("window %v is too short for %d requests", l.Window, l.Requests)
+	case l.Burst < 0:
+		return fmt.Errorf("burst must not be negative, got %d", l.Burst)
+	}
+	return nil
+}
+
+// routeLimit returns the per-route limit matching the current route, if any
+func (config *RateLimiterConfig) routeLimit(c *Context) (RateLimit, string, bool) {
+	if len(config.Routes) == 0 {
+		return RateLimit{}, "", false
+	}
+
+	pattern := c.RoutePattern()
+	scoped := c.Request.Method + " " + pattern
+	if limit, ok := config.Routes[scoped]; ok {
+		return limit, scoped, true
+	}
+	if limit, ok := config.Routes[pattern]; ok {
+		return limit, pattern, true
+	}
+	return RateLimit{}, "", false
+}
+
+// setRateLimitHeaders sets the RateLimit-* and Retry-After headers
+func setRateLimitHeaders(c *Context, limit RateLimit, result RateLimitResult) {
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Window)))
+	if !result.Allowed {
+		c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
+
+// takeRateLimit consumes one request from key using the selected algorithm
+func takeRateLimit(c *Context, store Store, key string, limit RateLimit, algorithm RateLimitAlgorithm) (RateLimitResult, error) {
+	var result RateLimitResult
+	now := time.Now()
+
+	err := store.Update(c.Request.Context(), key, limit.ttl(), func(current []byte) ([]byte, error) {
+		var err error
+		switch algorithm {
+		case FixedWindow:
+			result, current, err = fixedWindow(current, limit, now)
+		case TokenBucket, "":
+			result, current, err = tokenBucket(current, limit, now)
+		case SlidingLog:
+			result, current, err = slidingLog(current, limit, now)
+		case GCRA:
+			result, current, err = gcra(current, limit, now)
+		default:
+			err = fmt.Errorf("unknown rate limit algorithm %q", algorithm)
+		}
+		return current, err
+	})
+
+	return result, err
+}
+
+// burst returns the configured burst or the request count
+func (l RateLimit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// ttl returns how long a state must be kept before it is equivalent to a fresh one
+func (l RateLimit) ttl() time.Duration {
+	refill := l.Window * time.Duration(l.burst()) / time.Duration(l.Requests)
+	if refill < l.Window {
+		refill = l.Window
+	}
+	return refill + time.Second
+}
+
+// fixedWindow counts requests in consecutive windows of fixed length
+func fixedWindow(current []byte, limit RateLimit, now time.Time) (RateLimitResult, []byte, error) {
+	var state struct {
+		Start int64 `json:"s"`
+		Count int   `json:"c"`
+	}
+	if err := decodeRateState(current, &state); err != nil {
+		return RateLimitResult{}, nil, err
+	}
+
+	start := time.Unix(0, state.Start)
+	if current == nil || now.Sub(start) >= limit.Window {
+		start = now
+		state.Start = now.UnixNano()
+		state.Count = 0
+	}
+
+	reset := limit.Window - now.Sub(start)
+	result := RateLimitResult{Limit: limit.Requests, Reset: reset}
+	if state.Count < limit.Requests {
+		state.Count++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = reset
+	}
+	result.Remaining = limit.Requests - state.Count
+
+	value, err := json.Marshal(state)
+	return result, value, err
+}
+
+// tokenBucket refills Requests tokens per Window up to the burst size
+func tokenBucket(current []byte, limit RateLimit, now time.Time) (RateLimitResult, []byte, error) {
+	var state struct {
+		Tokens float64 `json:"t"`
+		Last   int64   `json:"l"`
+	}
+	if err := decodeRateState(current, &state); err != nil {
+		return RateLimitResult{}, nil, err
+	}
+
+	capacity := float64(limit.burst())
+	perToken := limit.Window / time.Duration(limit.Requests)
+	if current == nil {
+		state.Tokens = capacity
+	} else {
+		elapsed := now.Sub(time.Unix(0, state.Last))
+		state.Tokens = math.Min(capacity, state.Tokens+float64(elapsed)/float64(perToken))
+	}
+	state.Last = now.UnixNano()
+
+	result := RateLimitResult{Limit: limit.burst()}
+	if state.Tokens >= 1 {
+		state.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - state.Tokens) * float64(perToken))
+	}
+	result.Remaining = int(state.Tokens)
+	result.Reset = time.Duration((capacity - state.Tokens) * float64(perToken))
+
+	value, err := json.Marshal(state)
+	return result, value, err
+}
+
+// slidingLog keeps the timestamps of the requests in the last Window
+func slidingLog(current []byte, limit RateLimit, now time.Time) (RateLimitResult, []byte, error) {
+	var entries []int64
+	if err := decodeRateState(current, &entries); err != nil {
+		return RateLimitResult{}, nil, err
+	}
+
+	cutoff := now.Add(-limit.Window).UnixNano()
+	live := entries[:0]
+	for _, ts := range entries {
+		if ts > cutoff {
+			live = append(live, ts)
+		}
+	}
+
+	result := RateLimitResult{Limit: limit.Requests}
+	if len(live) < limit.Requests {
+		live = append(live, now.UnixNano())
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Unix(0, live[0]).Add(limit.Window).Sub(now)
+	}
+	result.Remaining = limit.Requests - len(live)
+	if len(live) > 0 {
+		result.Reset = time.Unix(0, live[len(live)-1]).Add(limit.Window).Sub(now)
+	}
+
+	value, err := json.Marshal(live)
+	return result, value, err
+}
+
+// gcra implements the generic cell rate algorithm, storing only the
+// theoretical arrival time of the next request
+func gcra(current []byte, limit RateLimit, now time.Time) (RateLimitResult, []byte, error) {
+	var state struct {
+		TAT int64 `json:"tat"`
+	}
+	if err := decodeRateState(current, &state); err != nil {
+		return RateLimitResult{}, nil, err
+	}
+
+	emission := limit.Window / time.Duration(limit.Requests)
+	tolerance := emission * time.Duration(limit.burst())
+
+	tat := time.Unix(0, state.TAT)
+	if current == nil || tat.Before(now) {
+		tat = now
+	}
+
+	newTAT := tat.Add(emission)
+	allowAt := newTAT.Add(-tolerance)
+
+	result := RateLimitResult{Limit: limit.burst()}
+	if now.Before(allowAt) {
+		result.RetryAfter = allowAt.Sub(now)
+		result.Reset = tat.Sub(now)
+	} else {
+		result.Allowed = true
+		tat = newTAT
+		result.Reset = newTAT.Sub(now)
+	}
+	result.Remaining = int((tolerance - tat.Sub(now)) / emission)
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	state.TAT = tat.UnixNano()
+
+	value, err := json.Marshal(state)
+	return result, value, err
+}
+
+// decodeRateState decodes a stored algorithm state, if any
+func decodeRateState(current []byte, state interface{}) error {
+	if current == nil {
+		return nil
+	}
+	if err := json.Unmarshal(current, state); err != nil {
+		return errors.New("corrupt rate limit state")
+	}
+	return nil
+}
+
+// KeyByIP keys requests by the client IP resolved through trusted proxies
+func KeyByIP(c *Context) string {
+	return c.RealIP()
+}
+
+// KeyByUserID keys requests by the authenticated user ID, falling back to the IP
+func KeyByUserID(c *Context) string {
+	if userID := GetUserID(c); userID != "" {
+		return "user:" + userID
+	}
+	return "ip:" + KeyByIP(c)
+}
+
+// KeyByHeader keys requests by a header value such as an API key.
+// Requests without the header are keyed by IP.
+func KeyByHeader(name string) RateLimitKeyFunc {
+	return func(c *Context) string {
+		if value := c.Request.Header.Get(name); value != "" {
+			return "header:" + value
+		}
+		return "ip:" + KeyByIP(c)
+	}
+}
+
+// KeyByRoute keys requests by the matched route, so all clients share a limit
+func KeyByRoute(c *Context) string {
+	return "route:" + c.Request.Method + " " + c.RoutePattern()
+}
+
+// CombineKeys joins several key functions into one composite key
+func CombineKeys(fns ...RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(c *Context) string {
+		key := ""
+		for i, fn := range fns {
+			if i > 0 {
+				key += "|"
+			}
+			key += fn(c)
+		}
+		return key
+	}
+}
@@ -0,0 +1,178 @@
+package forge
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serveRateLimited(app *Forge, method, path, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiterAlgorithms(t *testing.T) {
+	for _, algorithm := range []RateLimitAlgorithm{FixedWindow, TokenBucket, SlidingLog, GCRA} {
+		config := NewRateLimiterConfig(3, time.Minute)
+		config.Algorithm = algorithm
+
+		app := New()
+		app.Use(RateLimiterWithConfig(config))
+		app.GET("/test", func(c *Context) error {
+			return c.String(200, "OK")
+		})
+
+		for i := 0; i < 3; i++ {
+			// Different source ports must share the same key
+			w := serveRateLimited(app, "GET", "/test", "10.0.0.1:"+string(rune('1'+i))+"000")
+			if w.Code != 200 {
+				t.Fatalf("%s: request %d should pass, got %d", algorithm, i+1, w.Code)
+			}
+		}
+
+		w := serveRateLimited(app, "GET", "/test", "10.0.0.1:5000")
+		if w.Code != 429 {
+			t.Errorf("%s: expected 429, got %d", algorithm, w.Code)
+		}
+		if w.Header().Get("Retry-After") == "" || w.Header().Get("RateLimit-Remaining") != "0" {
+			t.Errorf("%s: missing rate limit headers: %v", algorithm, w.Header())
+		}
+
+		w = serveRateLimited(app, "GET", "/test", "10.0.0.2:5000")
+		if w.Code != 200 {
+			t.Errorf("%s: other clients should not be limited, got %d", algorithm, w.Code)
+		}
+	}
+}
+
+func TestRateLimiterIgnoresForwardedFor(t *testing.T) {
+	app := New()
+	app.Use(RateLimiter(1, time.Minute))
+	app.GET("/test", func(c *Context) error {
+		return c.String(200, "OK")
+	})
+
+	for i, spoofed := range []string{"1.1.1.1", "2.2.2.2"} {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", spoofed)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+
+		if i == 1 && w.Code != 429 {
+			t.Errorf("Spoofed X-Forwarded-For should not bypass the limit, got %d", w.Code)
+		}
+	}
+}
+
+func TestRateLimiterPerRoute(t *testing.T) {
+	config := NewRateLimiterConfig(100, time.Minute)
+	config.Routes["POST /login"] = RateLimit{Requests: 1, Window: time.Minute}
+
+	app := New()
+	app.Use(RateLimiterWithConfig(config))
+	app.POST("/login", func(c *Context) error { return c.String(200, "OK") })
+	app.GET("/home", func(c *Context) error { return c.String(200, "OK") })
+
+	serveRateLimited(app, "POST", "/login", "10.0.0.1:1")
+	if w := serveRateLimited(app, "POST", "/login", "10.0.0.1:1"); w.Code != 429 {
+		t.Errorf("Expected route limit to apply, got %d", w.Code)
+	}
+	if w := serveRateLimited(app, "GET", "/home", "10.0.0.1:1"); w.Code != 200 {
+		t.Errorf("Expected default limit for other routes, got %d", w.Code)
+	}
+}
+
+func TestRateLimiterInvalidConfigPanics(t *testing.T) {
+	zeroRoute := NewRateLimiterConfig(10, time.Minute)
+	zeroRoute.Routes["/other"] = RateLimit{}
+	tooShort := NewRateLimiterConfig(10, time.Nanosecond)
+	tooShort.Algorithm = GCRA
+
+	for name, build := range map[string]func() MiddlewareFunc{
+		"zero requests": func() MiddlewareFunc { return RateLimiter(0, time.Minute) },
+		"zero window":   func() MiddlewareFunc { return RateLimiter(10, 0) },
+		"zero route":    func() MiddlewareFunc { return RateLimiterWithConfig(zeroRoute) },
+		"short window":  func() MiddlewareFunc { return RateLimiterWithConfig(tooShort) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "rate limiter:") {
+					t.Errorf("%s: expected a rate limiter panic, got %v", name, r)
+				}
+			}()
+			build()
+		}()
+	}
+}
+
+func TestRateLimiterKeyByUserID(t *testing.T) {
+	config := NewRateLimiterConfig(1, time.Minute)
+	config.KeyFunc = KeyByUserID
+
+	app := New()
+	app.Use(func(c *Context) error {
+		if user := c.Request.Header.Get("X-User"); user != "" {
+			c.Set("user_id", user)
+		}
+		return c.Next()
+	})
+	app.Use(RateLimiterWithConfig(config))
+	app.GET("/test", func(c *Context) error { return c.String(200, "OK") })
+
+	request := func(user string) int {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = "10.0.0.1:1"
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Users behind the same IP get separate buckets
+	if request("alice") != 200 || request("bob") != 200 {
+		t.Fatal("Expected each user's first request to be allowed")
+	}
+	if code := request("alice"); code != 429 {
+		t.Errorf("Expected alice's second request to be limited, got %d", code)
+	}
+	if code := request("bob"); code != 429 {
+		t.Errorf("Expected bob's second request to be limited, got %d", code)
+	}
+
+	// Anonymous requests fall back to the IP bucket
+	if code := request(""); code != 200 {
+		t.Errorf("Expected anonymous request to use its own bucket, got %d", code)
+	}
+}
+
+func TestRateLimiterRedisStore(t *testing.T) {
+	fr := newFakeRedis(t)
+	client := NewRedisClient(NewRedisConfig(fr.Addr()))
+	defer client.Close()
+
+	// Two instances sharing one store share the limit
+	newApp := func() *Forge {
+		config := NewRateLimiterConfig(2, time.Minute)
+		config.Algorithm = GCRA
+		config.Store = NewRedisStore(client, "")
+		app := New()
+		app.Use(RateLimiterWithConfig(config))
+		app.GET("/test", func(c *Context) error { return c.String(200, "OK") })
+		return app
+	}
+	first, second := newApp(), newApp()
+
+	serveRateLimited(first, "GET", "/test", "10.0.0.1:1")
+	serveRateLimited(second, "GET", "/test", "10.0.0.1:1")
+	if w := serveRateLimited(first, "GET", "/test", "10.0.0.1:1"); w.Code != 429 {
+		t.Errorf("Expected shared limit across instances, got %d", w.Code)
+	}
+}
@@ -0,0 +1,408 @@
+package forge
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRedisClosed is returned when using a closed RedisClient
+var ErrRedisClosed = errors.New("redis: client is closed")
+
+// RedisError represents an error reply sent by the server
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// RedisConfig represents the configuration of a Redis-protocol client
+type RedisConfig struct {
+	Addr        string        // Server address (host:port)
+	Password    string        // AUTH password, empty to skip
+	DB          int           // Database selected after connecting
+	PoolSize    int           // Maximum idle connections kept
+	DialTimeout time.Duration // Timeout for establishing connections
+	IOTimeout   time.Duration // Read/write timeout when the context has no deadline
+}
+
+// NewRedisConfig creates a new Redis configuration
+func NewRedisConfig(addr string) *RedisConfig {
+	return &RedisConfig{
+		Addr:        addr,
+		PoolSize:    10,
+		DialTimeout: 5 * time.Second,
+		IOTimeout:   5 * time.Second,
+	}
+}
+
+// RedisClient is a minimal pooled client for servers speaking the Redis
+// serialization protocol (RESP). It only depends on the standard library.
+type RedisClient struct {
+	config *RedisConfig
+	idle   chan *redisConn
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewRedisClient creates a new client; connections are dialed lazily
+func NewRedisClient(config *RedisConfig) *RedisClient {
+	size := config.PoolSize
+	if size <= 0 {
+		size = 1
+	}
+	return &RedisClient{
+		config: config,
+		idle:   make(chan *redisConn, size),
+	}
+}
+
+// Do sends a single command and returns its reply. Replies are decoded as
+// string (simple strings), int64, []byte or nil (bulk strings) and
+// []interface{} (arrays). Error replies are returned as RedisError.
+func (rc *RedisClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := rc.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.do(ctx, rc.config.IOTimeout, args...)
+	rc.release(conn, err)
+	return reply, err
+}
+
+// Close closes every idle connection and rejects further commands
+func (rc *RedisClient) Close() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.closed {
+		return nil
+	}
+	rc.closed = true
+	close(rc.idle)
+	for conn := range rc.idle {
+		conn.Close()
+	}
+	return nil
+}
+
+// acquire returns an idle connection or dials a new one
+func (rc *RedisClient) acquire(ctx context.Context) (*redisConn, error) {
+	rc.mu.Lock()
+	closed := rc.closed
+	rc.mu.Unlock()
+	if closed {
+		return nil, ErrRedisClosed
+	}
+
+	select {
+	case conn, ok := <-rc.idle:
+		if ok {
+			return conn, nil
+		}
+		return nil, ErrRedisClosed
+	default:
+	}
+
+	return rc.dial(ctx)
+}
+
+// release returns a healthy connection to the pool
+func (rc *RedisClient) release(conn *redisConn, err error) {
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		conn.Close()
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.closed {
+		conn.Close()
+		return
+	}
+
+	select {
+	case rc.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// dial opens and initializes a new connection
+func (rc *RedisClient) dial(ctx context.Context) (*redisConn, error) {
+	dialer := net.Dialer{Timeout: rc.config.DialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", rc.config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: dial %s: %v", rc.config.Addr, err)
+	}
+
+	conn := newRedisConn(netConn)
+	if rc.config.Password != "" {
+		if _, err := conn.do(ctx, rc.config.IOTimeout, "AUTH", rc.config.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if rc.config.DB != 0 {
+		if _, err := conn.do(ctx, rc.config.IOTimeout, "SELECT", strconv.Itoa(rc.config.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// redisConn is a single RESP connection
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+func newRedisConn(conn net.Conn) *redisConn {
+	return &redisConn{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}
+}
+
+// do writes a command and reads its reply
+func (c *redisConn) do(ctx context.Context, timeout time.Duration, args ...string) (interface{}, error) {
+	c.setDeadline(ctx, timeout)
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.receive()
+}
+
+// setDeadline applies the context deadline or the default timeout
+func (c *redisConn) setDeadline(ctx context.Context, timeout time.Duration) {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+	} else if timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(timeout))
+	} else {
+		c.conn.SetDeadline(time.Time{})
+	}
+}
+
+// send writes a command as a RESP array of bulk strings
+func (c *redisConn) send(args ...string) error {
+	c.w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		c.w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		c.w.WriteString(arg)
+		c.w.WriteString("\r\n")
+	}
+	return c.w.Flush()
+}
+
+// receive reads a single RESP reply
+func (c *redisConn) receive() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := c.receive()
+			var redisErr RedisError
+			if err != nil && !errors.As(err, &redisErr) {
+				return nil, err
+			}
+			if err != nil {
+				item = err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+// readLine reads a CRLF-terminated line without the terminator
+func (c *redisConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("redis: malformed line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
+
+// Close closes the underlying connection
+func (c *redisConn) Close() error {
+	return c.conn.Close()
+}
+
+// RedisStore is a Store backed by a Redis-protocol server, so state such as
+// rate limits is shared across every Forge instance using the same server
+type RedisStore struct {
+	client     *RedisClient
+	prefix     string
+	maxRetries int
+}
+
+// NewRedisStore creates a new store; every key is prefixed with prefix
+func NewRedisStore(client *RedisClient, prefix string) *RedisStore {
+	return &RedisStore{
+		client:     client,
+		prefix:     prefix,
+		maxRetries: 25,
+	}
+}
+
+// Get returns the value stored under key
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := s.client.Do(ctx, "GET", s.prefix+key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+// Set stores value under key
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := s.client.Do(ctx, redisSetArgs(s.prefix+key, value, ttl)...)
+	return err
+}
+
+// Delete removes key from the store
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	_, err := s.client.Do(ctx, "DEL", s.prefix+key)
+	return err
+}
+
+// Update atomically replaces the value under key using optimistic locking
+// (WATCH/MULTI/EXEC), retrying when another writer changes the key first
+func (s *RedisStore) Update(ctx context.Context, key string, ttl time.Duration, fn StoreUpdateFunc) error {
+	conn, err := s.client.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.update(ctx, conn, s.prefix+key, ttl, fn)
+	s.client.release(conn, err)
+	return err
+}
+
+func (s *RedisStore) update(ctx context.Context, conn *redisConn, key string, ttl time.Duration, fn StoreUpdateFunc) error {
+	timeout := s.client.config.IOTimeout
+
+	for attempt := 0; attempt < s.maxRetries; attempt++ {
+		if _, err := conn.do(ctx, timeout, "WATCH", key); err != nil {
+			return err
+		}
+
+		reply, err := conn.do(ctx, timeout, "GET", key)
+		if err != nil {
+			return s.reset(ctx, conn, "UNWATCH", err)
+		}
+		current, _ := reply.([]byte)
+
+		value, err := fn(current)
+		if err != nil {
+			return s.reset(ctx, conn, "UNWATCH", err)
+		}
+
+		if _, err := conn.do(ctx, timeout, "MULTI"); err != nil {
+			return s.reset(ctx, conn, "UNWATCH", err)
+		}
+		if _, err := conn.do(ctx, timeout, redisSetArgs(key, value, ttl)...); err != nil {
+			return s.reset(ctx, conn, "DISCARD", err)
+		}
+		reply, err = conn.do(ctx, timeout, "EXEC")
+		if err != nil {
+			return err
+		}
+		if reply != nil {
+			return nil
+		}
+		// A nil EXEC reply means the watched key changed; back off and retry
+		backoff := time.Duration(mathrand.Int63n(int64(attempt+1) * int64(time.Millisecond)))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return fmt.Errorf("redis: update of %s aborted after %d conflicts", key, s.maxRetries)
+}
+
+// reset clears the WATCH (UNWATCH) or the open transaction (DISCARD, which
+// also unwatches) left by a failed update, so the connection can go back to
+// the pool. When the reset fails the error is returned unwrapped, so
+// release closes the connection instead of reusing it.
+func (s *RedisStore) reset(ctx context.Context, conn *redisConn, command string, err error) error {
+	if _, resetErr := conn.do(ctx, s.client.config.IOTimeout, command); resetErr != nil {
+		return fmt.Errorf("redis: %v (%s failed: %v)", err, command, resetErr)
+	}
+	return err
+}
+
+// redisSetArgs builds a SET command with an optional millisecond TTL
+func redisSetArgs(key string, value []byte, ttl time.Duration) []string {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		ms := ttl.Milliseconds()
+		if ms < 1 {
+			ms = 1
+		}
+		args = append(args, "PX", strconv.FormatInt(ms, 10))
+	}
+	return args
+}
@@ -0,0 +1,366 @@
+package forge
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a tiny in-process server speaking enough RESP to exercise
+// RedisClient, RedisStore and the pub/sub backplane
+type fakeRedis struct {
+	listener    net.Listener
+	mu          sync.Mutex
+	data        map[string]fakeRedisValue
+	versions    map[string]int
+	subscribers map[string]map[*fakeRedisSession]bool
+}
+
+type fakeRedisValue struct {
+	value   string
+	list    bool
+	expires time.Time
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	fr := &fakeRedis{
+		listener:    listener,
+		data:        make(map[string]fakeRedisValue),
+		versions:    make(map[string]int),
+		subscribers: make(map[string]map[*fakeRedisSession]bool),
+	}
+	go fr.serve()
+	t.Cleanup(func() { listener.Close() })
+	return fr
+}
+
+func (fr *fakeRedis) Addr() string {
+	return fr.listener.Addr().String()
+}
+
+func (fr *fakeRedis) serve() {
+	for {
+		conn, err := fr.listener.Accept()
+		if err != nil {
+			return
+		}
+		go fr.handle(conn)
+	}
+}
+
+// fakeRedisSession holds per-connection transaction and pub/sub state.
+// wmu serializes replies with messages pushed by publishers.
+type fakeRedisSession struct {
+	conn     net.Conn
+	wmu      sync.Mutex
+	w        *bufio.Writer
+	watched  map[string]int
+	queue    [][]string
+	multi    bool
+	channels map[string]bool
+}
+
+func (fr *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	s := &fakeRedisSession{
+		conn:     conn,
+		w:        bufio.NewWriter(conn),
+		watched:  make(map[string]int),
+		channels: make(map[string]bool),
+	}
+	defer fr.unsubscribeAll(s)
+
+	for {
+		args, err := readFakeCommand(r)
+		if err != nil {
+			return
+		}
+
+		s.wmu.Lock()
+		cmd := strings.ToUpper(args[0])
+		switch {
+		case s.multi && cmd != "EXEC" && cmd != "MULTI" && cmd != "DISCARD":
+			s.queue = append(s.queue, args)
+			s.w.WriteString("+QUEUED\r\n")
+		case cmd == "PUBLISH":
+			fmt.Fprintf(s.w, ":%d\r\n", fr.publish(args[1], args[2]))
+		case cmd == "SUBSCRIBE", cmd == "UNSUBSCRIBE":
+			fr.subscribe(s, cmd == "SUBSCRIBE", args[1:])
+		case cmd == "PING" && len(s.channels) > 0:
+			s.w.WriteString("*2\r\n$4\r\npong\r\n$0\r\n\r\n")
+		default:
+			fr.exec(s, args)
+		}
+		err = s.w.Flush()
+		s.wmu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// publish pushes a message to every subscriber and returns their number.
+// Subscribed sessions never publish, so locking their writers is safe.
+func (fr *fakeRedis) publish(channel, message string) int {
+	fr.mu.Lock()
+	sessions := make([]*fakeRedisSession, 0, len(fr.subscribers[channel]))
+	for s := range fr.subscribers[channel] {
+		sessions = append(sessions, s)
+	}
+	fr.mu.Unlock()
+
+	for _, s := range sessions {
+		s.wmu.Lock()
+		fmt.Fprintf(s.w, "*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(channel), channel, len(message), message)
+		s.w.Flush()
+		s.wmu.Unlock()
+	}
+	return len(sessions)
+}
+
+// subscribe handles SUBSCRIBE and UNSUBSCRIBE; the caller holds s.wmu
+func (fr *fakeRedis) subscribe(s *fakeRedisSession, subscribe bool, channels []string) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	kind := "unsubscribe"
+	if subscribe {
+		kind = "subscribe"
+	}
+	for _, channel := range channels {
+		if subscribe {
+			s.channels[channel] = true
+			if fr.subscribers[channel] == nil {
+				fr.subscribers[channel] = make(map[*fakeRedisSession]bool)
+			}
+			fr.subscribers[channel][s] = true
+		} else {
+			delete(s.channels, channel)
+			delete(fr.subscribers[channel], s)
+		}
+		fmt.Fprintf(s.w, "*3\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n:%d\r\n", len(kind), kind, len(channel), channel, len(s.channels))
+	}
+}
+
+func (fr *fakeRedis) unsubscribeAll(s *fakeRedisSession) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	for channel := range s.channels {
+		delete(fr.subscribers[channel], s)
+	}
+}
+
+// dropSubscribers closes every subscribed connection, as a server restart would
+func (fr *fakeRedis) dropSubscribers() {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	for _, sessions := range fr.subscribers {
+		for s := range sessions {
+			s.conn.Close()
+		}
+	}
+}
+
+func (fr *fakeRedis) exec(s *fakeRedisSession, args []string) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING", "AUTH", "SELECT":
+		s.w.WriteString("+OK\r\n")
+	case "WATCH":
+		for _, key := range args[1:] {
+			s.watched[key] = fr.versions[key]
+		}
+		s.w.WriteString("+OK\r\n")
+	case "UNWATCH":
+		s.watched = make(map[string]int)
+		s.w.WriteString("+OK\r\n")
+	case "FAKE.WATCHED":
+		// Test hook: the number of keys this connection still watches
+		fmt.Fprintf(s.w, ":%d\r\n", len(s.watched))
+	case "DISCARD":
+		s.multi = false
+		s.queue = nil
+		s.watched = make(map[string]int)
+		s.w.WriteString("+OK\r\n")
+	case "MULTI":
+		s.multi = true
+		s.queue = nil
+		s.w.WriteString("+OK\r\n")
+	case "EXEC":
+		s.multi = false
+		conflict := false
+		for key, version := range s.watched {
+			if fr.versions[key] != version {
+				conflict = true
+			}
+		}
+		s.watched = make(map[string]int)
+		if conflict {
+			s.w.WriteString("*-1\r\n")
+			return
+		}
+		fmt.Fprintf(s.w, "*%d\r\n", len(s.queue))
+		for _, queued := range s.queue {
+			fr.apply(s.w, queued)
+		}
+		s.queue = nil
+	default:
+		fr.apply(s.w, args)
+	}
+}
+
+// apply runs a data command; callers must hold the lock
+func (fr *fakeRedis) apply(w *bufio.Writer, args []string) {
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		entry, ok := fr.data[args[1]]
+		if !ok || (!entry.expires.IsZero() && time.Now().After(entry.expires)) {
+			w.WriteString("$-1\r\n")
+			return
+		}
+		if entry.list {
+			w.WriteString("-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+			return
+		}
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(entry.value), entry.value)
+	case "SET":
+		entry := fakeRedisValue{value: args[2]}
+		if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+			ms, _ := strconv.Atoi(args[4])
+			entry.expires = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		fr.data[args[1]] = entry
+		fr.versions[args[1]]++
+		w.WriteString("+OK\r\n")
+	case "LPUSH":
+		fr.data[args[1]] = fakeRedisValue{list: true}
+		fr.versions[args[1]]++
+		w.WriteString(":1\r\n")
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := fr.data[key]; ok {
+				delete(fr.data, key)
+				fr.versions[key]++
+				deleted++
+			}
+		}
+		fmt.Fprintf(w, ":%d\r\n", deleted)
+	default:
+		fmt.Fprintf(w, "-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+func readFakeCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRedisStore(t *testing.T) {
+	fr := newFakeRedis(t)
+	client := NewRedisClient(NewRedisConfig(fr.Addr()))
+	defer client.Close()
+	store := NewRedisStore(client, "test:")
+	ctx := context.Background()
+
+	if err := store.Set(ctx, "a", []byte("1"), time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	value, ok, err := store.Get(ctx, "a")
+	if err != nil || !ok || string(value) != "1" {
+		t.Fatalf("Expected '1', got %q ok=%v err=%v", value, ok, err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.Update(ctx, "counter", 0, func(current []byte) ([]byte, error) {
+				n, _ := strconv.Atoi(string(current))
+				return []byte(strconv.Itoa(n + 1)), nil
+			})
+			if err != nil {
+				t.Errorf("Update failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	value, _, _ = store.Get(ctx, "counter")
+	if string(value) != "8" {
+		t.Errorf("Expected counter 8 after concurrent updates, got %q", value)
+	}
+
+	if err := store.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok, _ := store.Get(ctx, "a"); ok {
+		t.Error("Expected key to be deleted")
+	}
+}
+
+func TestRedisStoreUpdateResetsConnectionOnError(t *testing.T) {
+	fr := newFakeRedis(t)
+	config := NewRedisConfig(fr.Addr())
+	config.PoolSize = 1
+	client := NewRedisClient(config)
+	defer client.Close()
+	store := NewRedisStore(client, "")
+	ctx := context.Background()
+
+	if _, err := client.Do(ctx, "LPUSH", "list", "x"); err != nil {
+		t.Fatal(err)
+	}
+	err := store.Update(ctx, "list", 0, func(current []byte) ([]byte, error) { return current, nil })
+	var redisErr RedisError
+	if !errors.As(err, &redisErr) {
+		t.Fatalf("expected WRONGTYPE error, got %v", err)
+	}
+
+	// The pooled connection must not keep the key watched
+	if watched, err := client.Do(ctx, "FAKE.WATCHED"); err != nil || watched != int64(0) {
+		t.Errorf("expected no watched keys on the pooled connection, got %v (%v)", watched, err)
+	}
+}
@@ -0,0 +1,126 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RevocationStore is a denylist of token IDs. Entries only need to be kept
+// until the revoked token would have expired anyway.
+type RevocationStore interface {
+	// Revoke denies id until expiresAt
+	Revoke(ctx context.Context, id string, expiresAt time.Time) error
+	// IsRevoked reports whether id is currently denied
+	IsRevoked(ctx context.Context, id string) (bool, error)
+}
+
+// StoreRevocationStore keeps revoked IDs in a Store (memory, Redis, ...)
+// with a TTL matching the token's remaining lifetime
+type StoreRevocationStore struct {
+	store  Store
+	prefix string
+}
+
+// NewStoreRevocationStore creates a revocation store on top of a Store
+func NewStoreRevocationStore(store Store, prefix string) *StoreRevocationStore {
+	return &StoreRevocationStore{store: store, prefix: prefix}
+}
+
+// NewMemoryRevocationStore creates an in-memory revocation store with TTL expiry
+func NewMemoryRevocationStore() *StoreRevocationStore {
+	return NewStoreRevocationStore(NewMemoryStore(), "revoked:")
+}
+
+// Revoke denies id until expiresAt
+func (s *StoreRevocationStore) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.store.Set(ctx, s.prefix+id, []byte{1}, ttl)
+}
+
+// IsRevoked reports whether id is denied
+func (s *StoreRevocationStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	_, ok, err := s.store.Get(ctx, s.prefix+id)
+	return ok, err
+}
+
+// FileRevocationStore keeps revoked IDs in memory and persists them to a
+// JSON file, so revocations survive restarts of a single-instance app
+type FileRevocationStore struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+// NewFileRevocationStore creates a file-backed revocation store, loading
+// any entries previously written to path
+func NewFileRevocationStore(path string) (*FileRevocationStore, error) {
+	s := &FileRevocationStore{path: path, entries: make(map[string]time.Time)}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.entries); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Revoke denies id until expiresAt and rewrites the file
+func (s *FileRevocationStore) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if !expiresAt.After(now) {
+		return nil
+	}
+	for key, expires := range s.entries {
+		if !expires.After(now) {
+			delete(s.entries, key)
+		}
+	}
+	s.entries[id] = expiresAt
+	return s.persist()
+}
+
+// IsRevoked reports whether id is denied
+func (s *FileRevocationStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires, ok := s.entries[id]
+	return ok && expires.After(time.Now()), nil
+}
+
+// persist writes the entries atomically; the caller holds s.mu
+func (s *FileRevocationStore) persist() error {
+	data, err := json.Marshal(s.entries)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".revoked-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
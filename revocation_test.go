@@ -0,0 +1,154 @@
+package forge
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenRotationAndReuse(t *testing.T) {
+	ctx := context.Background()
+	config := NewJWTConfig("secret")
+
+	pair, err := config.IssueTokenPair(ctx, map[string]interface{}{"sub": "42", "role": "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Refresh tokens are not accepted as access tokens
+	c := &Context{Request: httptest.NewRequest("GET", "/", nil)}
+	if _, err := config.authenticate(c, pair.RefreshToken); !errors.Is(err, ErrInvalidTokenUse) {
+		t.Errorf("Expected ErrInvalidTokenUse, got %v", err)
+	}
+
+	next, err := config.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwt, err := config.authenticate(c, next.AccessToken)
+	if err != nil || jwt.Payload.Subject != "42" || jwt.Payload.Claims["role"] != "admin" {
+		t.Fatalf("Expected rotated access token to keep claims, got %v %v", jwt, err)
+	}
+
+	// Replaying the old refresh token revokes the whole family
+	if _, err := config.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := config.authenticate(c, next.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected family access token to be revoked, got %v", err)
+	}
+	if _, err := config.Refresh(ctx, next.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected family refresh token to be revoked, got %v", err)
+	}
+}
+
+// recordingRevocations remembers when each revocation expires
+type recordingRevocations struct {
+	*StoreRevocationStore
+	until map[string]time.Time
+}
+
+func (r *recordingRevocations) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	r.until[id] = expiresAt
+	return r.StoreRevocationStore.Revoke(ctx, id, expiresAt)
+}
+
+func TestRevokeFamilyOutlivesAccessTokens(t *testing.T) {
+	ctx := context.Background()
+	revocations := &recordingRevocations{NewMemoryRevocationStore(), make(map[string]time.Time)}
+	config := NewJWTConfig("secret")
+	config.Expiration = 48 * time.Hour
+	config.RefreshExpiration = time.Hour
+	config.Revocations = revocations
+
+	pair, err := config.IssueTokenPair(ctx, map[string]interface{}{"sub": "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Context{Request: httptest.NewRequest("GET", "/", nil)}
+	jwt, err := config.authenticate(c, pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	family, _ := jwt.Payload.Claims[claimFamily].(string)
+	if err := config.RevokeFamily(ctx, family); err != nil {
+		t.Fatal(err)
+	}
+
+	until := revocations.until[familyKey(family)]
+	if accessExpiry := time.Unix(jwt.Payload.ExpirationTime, 0); until.Before(accessExpiry) {
+		t.Errorf("Expected revocation to last until %v, got %v", accessExpiry, until)
+	}
+	if _, err := config.authenticate(c, pair.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected access token to be revoked, got %v", err)
+	}
+
+	// Without lifetimes the revocation still lasts
+	config.Expiration, config.RefreshExpiration = 0, 0
+	if err := config.RevokeFamily(ctx, "other"); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := revocations.IsRevoked(ctx, familyKey("other")); !revoked {
+		t.Error("Expected family to be revoked with zero lifetimes")
+	}
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	config := NewJWTConfig("secret")
+	app := New()
+	app.POST("/refresh", RefreshHandler(config))
+	auth := app.Group("", JWTAuth(config))
+	auth.GET("/me", func(c *Context) error { return c.String(200, GetUserID(c)) })
+	auth.POST("/logout", Logout(config))
+
+	pair, _ := config.IssueTokenPair(context.Background(), map[string]interface{}{"sub": "42"})
+	request := func(method, path, token, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := request("GET", "/me", pair.AccessToken, ""); code != 200 {
+		t.Fatalf("Expected access token to work, got %d", code)
+	}
+	if code := request("POST", "/logout", pair.AccessToken, ""); code != 204 {
+		t.Fatalf("Expected logout to succeed, got %d", code)
+	}
+	if code := request("GET", "/me", pair.AccessToken, ""); code != 401 {
+		t.Errorf("Expected revoked access token to be rejected, got %d", code)
+	}
+	if code := request("POST", "/refresh", "", `{"refresh_token":"`+pair.RefreshToken+`"}`); code != 401 {
+		t.Errorf("Expected refresh after logout to fail, got %d", code)
+	}
+}
+
+func TestFileRevocationStorePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "revoked.json")
+
+	store, err := NewFileRevocationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Revoke(ctx, "active", time.Now().Add(time.Hour))
+	store.Revoke(ctx, "expired", time.Now().Add(-time.Hour))
+
+	reopened, err := NewFileRevocationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := reopened.IsRevoked(ctx, "active"); !revoked {
+		t.Error("Expected revocation to survive a restart")
+	}
+	if revoked, _ := reopened.IsRevoked(ctx, "expired"); revoked {
+		t.Error("Expected already-expired token to be ignored")
+	}
+}
@@ -0,0 +1,202 @@
+package forge
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CSPNonceSource is replaced by 'nonce-<value>' with the per-request nonce
+const CSPNonceSource = "{nonce}"
+
+// CSP builds a Content-Security-Policy header value
+type CSP struct {
+	directives []cspDirective
+}
+
+type cspDirective struct {
+	name    string
+	sources []string
+}
+
+// NewCSP creates an empty Content-Security-Policy
+func NewCSP() *CSP {
+	return &CSP{directives: make([]cspDirective, 0)}
+}
+
+// Add appends sources to a directive, creating it if needed
+func (p *CSP) Add(directive string, sources ...string) *CSP {
+	for i := range p.directives {
+		if p.directives[i].name == directive {
+			p.directives[i].sources = append(p.directives[i].sources, sources...)
+			return p
+		}
+	}
+	p.directives = append(p.directives, cspDirective{name: directive, sources: sources})
+	return p
+}
+
+func (p *CSP) DefaultSrc(sources ...string) *CSP     { return p.Add("default-src", sources...) }
+func (p *CSP) ScriptSrc(sources ...string) *CSP      { return p.Add("script-src", sources...) }
+func (p *CSP) StyleSrc(sources ...string) *CSP       { return p.Add("style-src", sources...) }
+func (p *CSP) ImgSrc(sources ...string) *CSP         { return p.Add("img-src", sources...) }
+func (p *CSP) ConnectSrc(sources ...string) *CSP     { return p.Add("connect-src", sources...) }
+func (p *CSP) FontSrc(sources ...string) *CSP        { return p.Add("font-src", sources...) }
+func (p *CSP) ObjectSrc(sources ...string) *CSP      { return p.Add("object-src", sources...) }
+func (p *CSP) FrameAncestors(sources ...string) *CSP { return p.Add("frame-ancestors", sources...) }
+func (p *CSP) BaseURI(sources ...string) *CSP        { return p.Add("base-uri", sources...) }
+func (p *CSP) FormAction(sources ...string) *CSP     { return p.Add("form-action", sources...) }
+func (p *CSP) ReportURI(uri string) *CSP             { return p.Add("report-uri", uri) }
+func (p *CSP) UpgradeInsecureRequests() *CSP         { return p.Add("upgrade-insecure-requests") }
+
+// UsesNonce reports whether any directive references CSPNonceSource
+func (p *CSP) UsesNonce() bool {
+	for _, directive := range p.directives {
+		for _, source := range directive.sources {
+			if source == CSPNonceSource {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Build returns the header value, substituting the nonce placeholder
+func (p *CSP) Build(nonce string) string {
+	parts := make([]string, 0, len(p.directives))
+	for _, directive := range p.directives {
+		part := directive.name
+		for _, source := range directive.sources {
+			if source == CSPNonceSource {
+				source = "'nonce-" + nonce + "'"
+			}
+			part += " " + source
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// SecureConfig represents security headers configuration.
+// Empty string fields and zero values omit the corresponding header.
+type SecureConfig struct {
+	HSTSMaxAge              int    // Strict-Transport-Security max-age in seconds (sent over HTTPS only)
+	HSTSIncludeSubdomains   bool   // Adds includeSubDomains to HSTS
+	HSTSPreload             bool   // Adds preload to HSTS
+	ContentTypeNosniff      bool   // Sends X-Content-Type-Options: nosniff
+	FrameOptions            string // X-Frame-Options (DENY, SAMEORIGIN)
+	ReferrerPolicy          string // Referrer-Policy
+	PermissionsPolicy       string // Permissions-Policy
+	CrossOriginOpenerPolicy string // Cross-Origin-Opener-Policy
+	ContentSecurityPolicy   *CSP   // Content-Security-Policy builder
+	CSPReportOnly           bool   // Sends Content-Security-Policy-Report-Only instead
+	HTTPSRedirect           bool   // Redirects plain HTTP requests to HTTPS
+	HTTPSHost               string // Host used for HTTPS redirects (defaults to the request host)
+	Skipper                 func(*Context) bool
+}
+
+// NewSecureConfig creates a secure configuration with strict defaults
+func NewSecureConfig() *SecureConfig {
+	return &SecureConfig{
+		HSTSMaxAge:              31536000,
+		HSTSIncludeSubdomains:   true,
+		ContentTypeNosniff:      true,
+		FrameOptions:            "DENY",
+		ReferrerPolicy:          "strict-origin-when-cross-origin",
+		CrossOriginOpenerPolicy: "same-origin",
+		ContentSecurityPolicy: NewCSP().
+			DefaultSrc("'self'").
+			ScriptSrc("'self'", CSPNonceSource).
+			ObjectSrc("'none'").
+			BaseURI("'self'").
+			FrameAncestors("'none'"),
+	}
+}
+
+// Secure creates a middleware that sets security headers. When the CSP
+// uses CSPNonceSource, a nonce is generated per request, stored in the
+// context and exposed to templates as {{cspNonce}}.
+func Secure(config *SecureConfig) MiddlewareFunc {
+	hsts := ""
+	if config.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(config.HSTSMaxAge)
+		if config.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if config.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	cspHeader := "Content-Security-Policy"
+	if config.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	useNonce := config.ContentSecurityPolicy != nil && config.ContentSecurityPolicy.UsesNonce()
+	staticCSP := ""
+	if config.ContentSecurityPolicy != nil && !useNonce {
+		staticCSP = config.ContentSecurityPolicy.Build("")
+	}
+
+	return func(c *Context) error {
+		if config.Skipper != nil && config.Skipper(c) {
+			return c.Next()
+		}
+
+		https := c.Scheme() == "https"
+		if config.HTTPSRedirect && !https {
+			host := config.HTTPSHost
+			if host == "" {
+				host = c.Host()
+			}
+			status := http.StatusPermanentRedirect
+			if c.Request.Method == "GET" || c.Request.Method == "HEAD" {
+				status = http.StatusMovedPermanently
+			}
+			return c.Redirect(status, "https://"+host+c.Request.URL.RequestURI())
+		}
+
+		header := c.Response.Header()
+		if hsts != "" && https {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		if config.ContentTypeNosniff {
+			header.Set("X-Content-Type-Options", "nosniff")
+		}
+		if config.FrameOptions != "" {
+			header.Set("X-Frame-Options", config.FrameOptions)
+		}
+		if config.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", config.ReferrerPolicy)
+		}
+		if config.PermissionsPolicy != "" {
+			header.Set("Permissions-Policy", config.PermissionsPolicy)
+		}
+		if config.CrossOriginOpenerPolicy != "" {
+			header.Set("Cross-Origin-Opener-Policy", config.CrossOriginOpenerPolicy)
+		}
+
+		if useNonce {
+			nonce, err := randomToken(16)
+			if err != nil {
+				return fmt.Errorf("failed to generate CSP nonce: %v", err)
+			}
+			c.Set("csp_nonce", nonce)
+			c.SetTemplateFunc("cspNonce", func() string { return nonce })
+			header.Set(cspHeader, config.ContentSecurityPolicy.Build(nonce))
+		} else if staticCSP != "" {
+			header.Set(cspHeader, staticCSP)
+		}
+
+		return c.Next()
+	}
+}
+
+// GetCSPNonce returns the CSP nonce generated for the current request
+func GetCSPNonce(c *Context) string {
+	if nonce, ok := c.Get("csp_nonce").(string); ok {
+		return nonce
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package forge
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	app := New()
+	app.SetTrustedProxies("127.0.0.1")
+	app.Use(Secure(NewSecureConfig()))
+	app.GET("/", func(c *Context) error { return c.String(200, GetCSPNonce(c)) })
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Header().Get("Strict-Transport-Security") != "max-age=31536000; includeSubDomains" {
+		t.Errorf("Unexpected HSTS header %q", w.Header().Get("Strict-Transport-Security"))
+	}
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" || w.Header().Get("X-Frame-Options") != "DENY" {
+		t.Errorf("Missing security headers: %v", w.Header())
+	}
+
+	nonce := w.Body.String()
+	csp := w.Header().Get("Content-Security-Policy")
+	if nonce == "" || !strings.Contains(csp, "'nonce-"+nonce+"'") {
+		t.Errorf("Expected CSP to contain nonce %q, got %q", nonce, csp)
+	}
+
+	// HSTS is never sent over plain HTTP
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("HSTS must not be sent over HTTP")
+	}
+}
+
+func TestSecureHTTPSRedirect(t *testing.T) {
+	config := NewSecureConfig()
+	config.HTTPSRedirect = true
+
+	app := New()
+	app.Use(Secure(config))
+	app.GET("/page", func(c *Context) error { return c.String(200, "OK") })
+
+	req := httptest.NewRequest("GET", "http://example.com/page?x=1", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	if w.Code != 301 || w.Header().Get("Location") != "https://example.com/page?x=1" {
+		t.Errorf("Expected redirect to HTTPS, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestSecureNonceInTemplates(t *testing.T) {
+	dir := t.TempDir()
+	page := `<script nonce="{{cspNonce}}">ok()</script>`
+	if err := os.WriteFile(filepath.Join(dir, "page.html"), []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewTemplateEngine(dir, "html")
+	if err := engine.LoadTemplates(); err != nil {
+		t.Fatalf("LoadTemplates failed: %v", err)
+	}
+
+	app := New()
+	app.SetTemplateEngine(engine)
+	app.Use(Secure(NewSecureConfig()))
+	app.GET("/", func(c *Context) error { return c.Render(200, "page", nil) })
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		csp := w.Header().Get("Content-Security-Policy")
+		start := strings.Index(csp, "'nonce-") + len("'nonce-")
+		nonce := csp[start : start+strings.Index(csp[start:], "'")]
+		if !strings.Contains(w.Body.String(), `nonce="`+nonce+`"`) {
+			t.Errorf("Expected rendered nonce %q, got %s", nonce, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package forge
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned when reading protected cookies
+var (
+	ErrInvalidCookie = errors.New("invalid or tampered cookie")
+	ErrCookieExpired = errors.New("cookie expired")
+	ErrNoCookieKeys  = errors.New("cookie keys are not configured")
+)
+
+// cookieKeys signs and encrypts cookie values. Separate signing and
+// encryption keys are derived from each master key; the first key is used
+// for new cookies and every key is accepted, which allows rotation.
+type cookieKeys struct {
+	signing [][]byte
+	cipher  *cookieCipher
+}
+
+// newCookieKeys derives signing and encryption keys from master keys
+func newCookieKeys(masters ...[]byte) (*cookieKeys, error) {
+	if len(masters) == 0 {
+		return nil, errors.New("at least one cookie key is required")
+	}
+
+	keys := &cookieKeys{signing: make([][]byte, 0, len(masters))}
+	encryption := make([][]byte, 0, len(masters))
+	for _, master := range masters {
+		keys.signing = append(keys.signing, deriveKey(master, "forge-cookie-sign"))
+		encryption = append(encryption, deriveKey(master, "forge-cookie-encrypt"))
+	}
+
+	cc, err := newCookieCipher(encryption...)
+	if err != nil {
+		return nil, err
+	}
+	keys.cipher = cc
+	return keys, nil
+}
+
+// deriveKey derives a purpose-specific key from a master key
+func deriveKey(master []byte, purpose string) []byte {
+	h := hmac.New(sha256.New, master)
+	h.Write([]byte(purpose))
+	return h.Sum(nil)
+}
+
+// sign returns "value.expires.mac" where value is base64-encoded and the
+// MAC covers the cookie name, so values cannot be moved between cookies
+func (k *cookieKeys) sign(name, value string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value)) + "." + formatCookieExpiry(expires)
+	return payload + "." + cookieMAC(k.signing[0], name, payload)
+}
+
+// verify checks a value produced by sign and returns the original value
+func (k *cookieKeys) verify(name, signed string, now time.Time) (string, error) {
+	i := strings.LastIndexByte(signed, '.')
+	if i < 0 {
+		return "", ErrInvalidCookie
+	}
+	payload, mac := signed[:i], signed[i+1:]
+
+	valid := false
+	for _, key := range k.signing {
+		if hmac.Equal([]byte(mac), []byte(cookieMAC(key, name, payload))) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", ErrInvalidCookie
+	}
+
+	encoded, expiry, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", ErrInvalidCookie
+	}
+	if err := checkCookieExpiry(expiry, now); err != nil {
+		return "", err
+	}
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	return string(value), nil
+}
+
+// encrypt seals a value together with its expiry
+func (k *cookieKeys) encrypt(name, value string, expires time.Time) (string, error) {
+	return k.cipher.encrypt(name, []byte(formatCookieExpiry(expires)+"|"+value))
+}
+
+// decrypt opens a value produced by encrypt
+func (k *cookieKeys) decrypt(name, sealed string, now time.Time) (string, error) {
+	plaintext, err := k.cipher.decrypt(name, sealed)
+	if err != nil {
+		return "", err
+	}
+	expiry, value, ok := strings.Cut(string(plaintext), "|")
+	if !ok {
+		return "", ErrInvalidCookie
+	}
+	if err := checkCookieExpiry(expiry, now); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+func cookieMAC(key []byte, name, payload string) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name + "=" + payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// formatCookieExpiry encodes an expiry as Unix seconds ("0" for none)
+func formatCookieExpiry(expires time.Time) string {
+	if expires.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(expires.Unix(), 10)
+}
+
+// checkCookieExpiry rejects values whose embedded expiry has passed, so a
+// replayed cookie stops working even if the client ignores Max-Age
+func checkCookieExpiry(expiry string, now time.Time) error {
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return ErrInvalidCookie
+	}
+	if unix != 0 && now.Unix() > unix {
+		return ErrCookieExpired
+	}
+	return nil
+}
+
+// cookieCipher encrypts and authenticates cookie values with AES-GCM.
+// The first key encrypts; every key is tried when decrypting so keys can
+// be rotated by prepending a new one.
+type cookieCipher struct {
+	aeads []cipher.AEAD
+}
+
+// newCookieCipher derives an AES-256 key from each secret
+func newCookieCipher(secrets ...[]byte) (*cookieCipher, error) {
+	if len(secrets) == 0 {
+		return nil, errors.New("at least one encryption key is required")
+	}
+
+	cc := &cookieCipher{aeads: make([]cipher.AEAD, 0, len(secrets))}
+	for _, secret := range secrets {
+		key := sha256.Sum256(secret)
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		cc.aeads = append(cc.aeads, aead)
+	}
+	return cc, nil
+}
+
+// encrypt seals plaintext, binding it to the cookie name
+func (cc *cookieCipher) encrypt(name string, plaintext []byte) (string, error) {
+	aead := cc.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(name))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a value produced by encrypt with any of the keys
+func (cc *cookieCipher) decrypt(name, value string) ([]byte, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+
+	for _, aead := range cc.aeads {
+		if len(sealed) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, ErrInvalidCookie
+}
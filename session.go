@@ -0,0 +1,630 @@
+package forge
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionUserKey is the session value holding the authenticated user ID
+const sessionUserKey = "_user_id"
+
+// sessionFlashKey is the session value holding pending flash messages
+const sessionFlashKey = "_flash"
+
+// SessionData represents the persisted state of a session
+type SessionData struct {
+	ID         string                 `json:"id"`
+	Values     map[string]interface{} `json:"values"`
+	CreatedAt  time.Time              `json:"created_at"`
+	LastAccess time.Time              `json:"last_access"`
+}
+
+// SessionStore persists session data. Load receives the cookie value and
+// returns nil when no session exists; Save returns the new cookie value.
+type SessionStore interface {
+	Load(ctx context.Context, cookieValue string) (*SessionData, error)
+	Save(ctx context.Context, data *SessionData, ttl time.Duration) (string, error)
+	Delete(ctx context.Context, data *SessionData) error
+}
+
+// Session represents the session of the current request.
+// Values must be JSON-serializable; numbers come back as float64.
+type Session struct {
+	data      *SessionData
+	previous  *SessionData
+	isNew     bool
+	modified  bool
+	destroyed bool
+	mu        sync.Mutex
+}
+
+// newSession creates an empty session with a fresh ID
+func newSession() (*Session, error) {
+	id, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &Session{
+		data: &SessionData{
+			ID:         id,
+			Values:     make(map[string]interface{}),
+			CreatedAt:  now,
+			LastAccess: now,
+		},
+		isNew: true,
+	}, nil
+}
+
+// ID returns the session ID
+func (s *Session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.ID
+}
+
+// IsNew reports whether the session was created by this request
+func (s *Session) IsNew() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isNew
+}
+
+// Get returns a session value
+func (s *Session) Get(key string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.Values[key]
+}
+
+// Set sets a session value
+func (s *Session) Set(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Values[key] = value
+	s.modified = true
+}
+
+// Delete removes a session value
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data.Values, key)
+	s.modified = true
+}
+
+// Flash adds a message that is kept until it is read with Flashes
+func (s *Session) Flash(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flashes, _ := s.data.Values[sessionFlashKey].(map[string]interface{})
+	if flashes == nil {
+		flashes = make(map[string]interface{})
+	}
+	list, _ := flashes[key].([]interface{})
+	flashes[key] = append(list, value)
+	s.data.Values[sessionFlashKey] = flashes
+	s.modified = true
+}
+
+// Flashes returns and clears the flash messages stored under key
+func (s *Session) Flashes(key string) []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flashes, _ := s.data.Values[sessionFlashKey].(map[string]interface{})
+	list, _ := flashes[key].([]interface{})
+	if list == nil {
+		return nil
+	}
+	delete(flashes, key)
+	if len(flashes) == 0 {
+		delete(s.data.Values, sessionFlashKey)
+	}
+	s.modified = true
+	return list
+}
+
+// Regenerate assigns a new session ID while keeping the values, so a
+// session fixed by an attacker before login cannot be reused afterwards
+func (s *Session) Regenerate() error {
+	id, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.previous == nil && !s.isNew {
+		old := *s.data
+		s.previous = &old
+	}
+	s.data.ID = id
+	s.data.CreatedAt = time.Now()
+	s.modified = true
+	return nil
+}
+
+// Login regenerates the session and binds it to a user ID, which the
+// Sessions middleware exposes through GetUserID on later requests
+func (s *Session) Login(userID string) error {
+	if err := s.Regenerate(); err != nil {
+		return err
+	}
+	s.Set(sessionUserKey, userID)
+	return nil
+}
+
+// UserID returns the user ID bound with Login
+func (s *Session) UserID() string {
+	userID, _ := s.Get(sessionUserKey).(string)
+	return userID
+}
+
+// Destroy removes the session from the store and expires the cookie
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.destroyed = true
+}
+
+// SessionConfig represents session middleware configuration
+type SessionConfig struct {
+	Store           SessionStore
+	CookieName      string
+	CookiePath      string
+	CookieDomain    string
+	CookieSecure    bool
+	CookieHTTPOnly  bool
+	CookieSameSite  http.SameSite
+	IdleTimeout     time.Duration // Expires sessions not used for this long
+	AbsoluteTimeout time.Duration // Expires sessions this long after creation or login
+	TouchInterval   time.Duration // Minimum time between saves of unmodified sessions
+}
+
+// NewSessionConfig creates a new session configuration
+func NewSessionConfig(store SessionStore) *SessionConfig {
+	return &SessionConfig{
+		Store:           store,
+		CookieName:      "forge_session",
+		CookiePath:      "/",
+		CookieSecure:    true,
+		CookieHTTPOnly:  true,
+		CookieSameSite:  http.SameSiteLaxMode,
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: 24 * time.Hour,
+		TouchInterval:   time.Minute,
+	}
+}
+
+// Sessions creates a session middleware. The session is saved right
+// before the response headers are written. When the session is bound to
+// a user (Session.Login), the user ID is exposed through GetUserID; when
+// an authentication middleware running earlier (such as JWTAuth) set a
+// different user ID, the session is reset so it cannot leak across users.
+func Sessions(config *SessionConfig) MiddlewareFunc {
+	return func(c *Context) error {
+		session, err := config.load(c)
+		if err != nil {
+			return fmt.Errorf("session: %v", err)
+		}
+
+		if authUser := GetUserID(c); authUser != "" {
+			if sessionUser := session.UserID(); sessionUser != "" && sessionUser != authUser {
+				session.mu.Lock()
+				session.data.Values = make(map[string]interface{})
+				session.mu.Unlock()
+				if err := session.Regenerate(); err != nil {
+					return fmt.Errorf("session: %v", err)
+				}
+			}
+		} else if sessionUser := session.UserID(); sessionUser != "" {
+			c.Set("user_id", sessionUser)
+		}
+
+		c.Set("session", session)
+
+		writer := &hookedResponseWriter{ResponseWriter: c.Response}
+		writer.before = func() {
+			if err := config.commit(c, session); err != nil {
+				log.Printf("session: failed to save: %v", err)
+			}
+		}
+		c.Response = writer
+
+		err = c.Next()
+		writer.runHook()
+		return err
+	}
+}
+
+// load returns the session referenced by the request cookie or a new one
+func (config *SessionConfig) load(c *Context) (*Session, error) {
+	cookie, err := c.Request.Cookie(config.CookieName)
+	if err != nil || cookie.Value == "" {
+		return newSession()
+	}
+
+	data, err := config.Store.Load(c.Request.Context(), cookie.Value)
+	if err != nil && !errors.Is(err, ErrInvalidCookie) {
+		return nil, err
+	}
+	if data == nil {
+		return newSession()
+	}
+
+	now := time.Now()
+	idleExpired := config.IdleTimeout > 0 && now.Sub(data.LastAccess) > config.IdleTimeout
+	absoluteExpired := config.AbsoluteTimeout > 0 && now.Sub(data.CreatedAt) > config.AbsoluteTimeout
+	if idleExpired || absoluteExpired {
+		if err := config.Store.Delete(c.Request.Context(), data); err != nil {
+			return nil, err
+		}
+		return newSession()
+	}
+
+	if data.Values == nil {
+		data.Values = make(map[string]interface{})
+	}
+	return &Session{data: data}, nil
+}
+
+// commit persists the session and writes the cookie
+func (config *SessionConfig) commit(c *Context, s *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := c.Request.Context()
+	if s.previous != nil {
+		if err := config.Store.Delete(ctx, s.previous); err != nil {
+			return err
+		}
+		s.previous = nil
+	}
+
+	if s.destroyed {
+		if !s.isNew {
+			if err := config.Store.Delete(ctx, s.data); err != nil {
+				return err
+			}
+		}
+		config.setCookie(c, "", -1)
+		return nil
+	}
+
+	now := time.Now()
+	touch := !s.isNew && now.Sub(s.data.LastAccess) > config.TouchInterval
+	if !s.modified && !touch {
+		return nil
+	}
+
+	s.data.LastAccess = now
+	ttl := config.IdleTimeout
+	if config.AbsoluteTimeout > 0 {
+		remaining := s.data.CreatedAt.Add(config.AbsoluteTimeout).Sub(now)
+		if ttl <= 0 || remaining < ttl {
+			ttl = remaining
+		}
+	}
+
+	value, err := config.Store.Save(ctx, s.data, ttl)
+	if err != nil {
+		return err
+	}
+
+	maxAge := 0
+	if config.AbsoluteTimeout > 0 {
+		maxAge = int(s.data.CreatedAt.Add(config.AbsoluteTimeout).Sub(now).Seconds())
+	}
+	config.setCookie(c, value, maxAge)
+	s.isNew = false
+	s.modified = false
+	return nil
+}
+
+// setCookie writes the session cookie
+func (config *SessionConfig) setCookie(c *Context, value string, maxAge int) {
+	c.SetCookie(&http.Cookie{
+		Name:     config.CookieName,
+		Value:    value,
+		Path:     config.CookiePath,
+		Domain:   config.CookieDomain,
+		MaxAge:   maxAge,
+		Secure:   config.CookieSecure,
+		HttpOnly: config.CookieHTTPOnly,
+		SameSite: config.CookieSameSite,
+	})
+}
+
+// Session returns the session of the current request, or nil when the
+// Sessions middleware is not installed
+func (c *Context) Session() *Session {
+	if session, ok := c.Get("session").(*Session); ok {
+		return session
+	}
+	return nil
+}
+
+// hookedResponseWriter runs a hook once, right before the response
+// headers are written
+type hookedResponseWriter struct {
+	http.ResponseWriter
+	before func()
+	done   bool
+}
+
+func (w *hookedResponseWriter) runHook() {
+	if !w.done {
+		w.done = true
+		w.before()
+	}
+}
+
+func (w *hookedResponseWriter) WriteHeader(status int) {
+	w.runHook()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *hookedResponseWriter) Write(b []byte) (int, error) {
+	w.runHook()
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher
+func (w *hookedResponseWriter) Flush() {
+	w.runHook()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker so WebSocket upgrades keep working. The
+// hook still runs so its headers reach the handshake response.
+func (w *hookedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.runHook()
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *hookedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// CookieSessionStore keeps the whole session in an encrypted and
+// authenticated cookie. Pass several keys to rotate: the first encrypts.
+type CookieSessionStore struct {
+	cipher *cookieCipher
+}
+
+// NewCookieSessionStore creates a new cookie session store
+func NewCookieSessionStore(keys ...[]byte) (*CookieSessionStore, error) {
+	cc, err := newCookieCipher(keys...)
+	if err != nil {
+		return nil, err
+	}
+	return &CookieSessionStore{cipher: cc}, nil
+}
+
+// Load decrypts the session from the cookie value
+func (s *CookieSessionStore) Load(ctx context.Context, cookieValue string) (*SessionData, error) {
+	plaintext, err := s.cipher.decrypt("session", cookieValue)
+	if err != nil {
+		return nil, err
+	}
+	var data SessionData
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return nil, ErrInvalidCookie
+	}
+	return &data, nil
+}
+
+// Save encrypts the session into a cookie value
+func (s *CookieSessionStore) Save(ctx context.Context, data *SessionData, ttl time.Duration) (string, error) {
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	value, err := s.cipher.encrypt("session", plaintext)
+	if err != nil {
+		return "", err
+	}
+	if len(value) > 4000 {
+		return "", fmt.Errorf("session too large for a cookie (%d bytes)", len(value))
+	}
+	return value, nil
+}
+
+// Delete is a no-op; the middleware expires the cookie
+func (s *CookieSessionStore) Delete(ctx context.Context, data *SessionData) error {
+	return nil
+}
+
+// StoreSessionStore keeps sessions in a Store (memory, Redis, ...) and
+// only sends the session ID to the client
+type StoreSessionStore struct {
+	store  Store
+	prefix string
+}
+
+// NewStoreSessionStore creates a session store on top of a Store
+func NewStoreSessionStore(store Store, prefix string) *StoreSessionStore {
+	return &StoreSessionStore{store: store, prefix: prefix}
+}
+
+// NewMemorySessionStore creates an in-memory session store with TTL expiry
+func NewMemorySessionStore() *StoreSessionStore {
+	return NewStoreSessionStore(NewMemoryStore(), "session:")
+}
+
+// Load returns the session stored under the cookie ID
+func (s *StoreSessionStore) Load(ctx context.Context, cookieValue string) (*SessionData, error) {
+	if !validSessionID(cookieValue) {
+		return nil, nil
+	}
+	value, ok, err := s.store.Get(ctx, s.prefix+cookieValue)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var data SessionData
+	if err := json.Unmarshal(value, &data); err != nil {
+		return nil, nil
+	}
+	return &data, nil
+}
+
+// Save stores the session and returns its ID
+func (s *StoreSessionStore) Save(ctx context.Context, data *SessionData, ttl time.Duration) (string, error) {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	if err := s.store.Set(ctx, s.prefix+data.ID, value, ttl); err != nil {
+		return "", err
+	}
+	return data.ID, nil
+}
+
+// Delete removes the session
+func (s *StoreSessionStore) Delete(ctx context.Context, data *SessionData) error {
+	return s.store.Delete(ctx, s.prefix+data.ID)
+}
+
+// FileSessionStore keeps each session in a JSON file in a directory
+type FileSessionStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+type fileSession struct {
+	Expires time.Time    `json:"expires"`
+	Data    *SessionData `json:"data"`
+}
+
+// NewFileSessionStore creates a file-system session store
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %v", err)
+	}
+	return &FileSessionStore{dir: dir}, nil
+}
+
+// Load reads the session file referenced by the cookie ID
+func (s *FileSessionStore) Load(ctx context.Context, cookieValue string) (*SessionData, error) {
+	if !validSessionID(cookieValue) {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, err := os.ReadFile(s.path(cookieValue))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored fileSession
+	if err := json.Unmarshal(content, &stored); err != nil || stored.Data == nil {
+		return nil, nil
+	}
+	if time.Now().After(stored.Expires) {
+		os.Remove(s.path(cookieValue))
+		return nil, nil
+	}
+	return stored.Data, nil
+}
+
+// Save writes the session file atomically and returns the session ID
+func (s *FileSessionStore) Save(ctx context.Context, data *SessionData, ttl time.Duration) (string, error) {
+	content, err := json.Marshal(fileSession{Expires: time.Now().Add(ttl), Data: data})
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(s.dir, "tmp_*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), s.path(data.ID)); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return data.ID, nil
+}
+
+// Delete removes the session file
+func (s *FileSessionStore) Delete(ctx context.Context, data *SessionData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path(data.ID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Cleanup removes expired session files
+func (s *FileSessionStore) Cleanup() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "sess_*.json"))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var stored fileSession
+		if err := json.Unmarshal(content, &stored); err != nil || now.After(stored.Expires) {
+			os.Remove(file)
+		}
+	}
+	return nil
+}
+
+func (s *FileSessionStore) path(id string) string {
+	return filepath.Join(s.dir, "sess_"+id+".json")
+}
+
+// validSessionID checks that an ID only contains URL-safe base64
+// characters, so it can be used as a key or file name
+func validSessionID(id string) bool {
+	if len(id) < 16 || len(id) > 128 {
+		return false
+	}
+	return strings.Trim(id, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_") == ""
+}
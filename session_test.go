@@ -0,0 +1,169 @@
+package forge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newSessionApp(config *SessionConfig) *Forge {
+	app := New()
+	app.Use(Sessions(config))
+	app.POST("/login", func(c *Context) error {
+		if err := c.Session().Login("42"); err != nil {
+			return err
+		}
+		c.Session().Flash("notice", "welcome")
+		return c.String(200, c.Session().ID())
+	})
+	app.GET("/me", func(c *Context) error {
+		flashes := c.Session().Flashes("notice")
+		if len(flashes) > 0 {
+			return c.String(200, GetUserID(c)+":"+flashes[0].(string))
+		}
+		return c.String(200, GetUserID(c))
+	})
+	app.POST("/logout", func(c *Context) error {
+		c.Session().Destroy()
+		return c.String(200, "bye")
+	})
+	return app
+}
+
+func doSessionRequest(app *Forge, method, path string, cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Cookie) {
+	req := httptest.NewRequest(method, path, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "forge_session" {
+			return w, c
+		}
+	}
+	return w, cookie
+}
+
+func TestSessionStores(t *testing.T) {
+	cookieStore, err := NewCookieSessionStore([]byte("new-key"), []byte("old-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileStore, err := NewFileSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]SessionStore{
+		"cookie": cookieStore,
+		"memory": NewMemorySessionStore(),
+		"file":   fileStore,
+	}
+
+	for name, store := range stores {
+		app := newSessionApp(NewSessionConfig(store))
+
+		w, cookie := doSessionRequest(app, "POST", "/login", nil)
+		if cookie == nil || w.Code != 200 {
+			t.Fatalf("%s: login did not set a session cookie", name)
+		}
+
+		w, cookie = doSessionRequest(app, "GET", "/me", cookie)
+		if w.Body.String() != "42:welcome" {
+			t.Errorf("%s: expected user and flash, got %q", name, w.Body.String())
+		}
+
+		// Flashes are consumed after being read
+		w, cookie = doSessionRequest(app, "GET", "/me", cookie)
+		if w.Body.String() != "42" {
+			t.Errorf("%s: expected flash to be consumed, got %q", name, w.Body.String())
+		}
+
+		w, expired := doSessionRequest(app, "POST", "/logout", cookie)
+		if expired.MaxAge >= 0 {
+			t.Errorf("%s: expected logout to expire the cookie", name)
+		}
+		if name != "cookie" {
+			// Server-side stores no longer accept the old ID
+			w, _ = doSessionRequest(app, "GET", "/me", cookie)
+			if w.Body.String() != "" {
+				t.Errorf("%s: expected destroyed session, got %q", name, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestSessionRegenerateOnLogin(t *testing.T) {
+	store := NewMemorySessionStore()
+	app := newSessionApp(NewSessionConfig(store))
+	app.GET("/visit", func(c *Context) error {
+		c.Session().Set("visited", true)
+		return c.String(200, c.Session().ID())
+	})
+
+	w, cookie := doSessionRequest(app, "GET", "/visit", nil)
+	before := w.Body.String()
+
+	w, cookie = doSessionRequest(app, "POST", "/login", cookie)
+	if w.Body.String() == before || cookie.Value == before {
+		t.Error("Expected a new session ID after login")
+	}
+
+	// The pre-login ID must not be usable any more
+	w, _ = doSessionRequest(app, "GET", "/me", &http.Cookie{Name: "forge_session", Value: before})
+	if w.Body.String() != "" {
+		t.Errorf("Old session ID still valid: %q", w.Body.String())
+	}
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	config := NewSessionConfig(NewMemorySessionStore())
+	config.IdleTimeout = 20 * time.Millisecond
+	app := newSessionApp(config)
+
+	_, cookie := doSessionRequest(app, "POST", "/login", nil)
+	time.Sleep(40 * time.Millisecond)
+
+	w, _ := doSessionRequest(app, "GET", "/me", cookie)
+	if w.Body.String() != "" {
+		t.Errorf("Expected idle session to expire, got %q", w.Body.String())
+	}
+}
+
+func TestSessionAbsoluteTimeout(t *testing.T) {
+	config := NewSessionConfig(NewMemorySessionStore())
+	config.IdleTimeout = time.Hour
+	config.AbsoluteTimeout = 100 * time.Millisecond
+	app := newSessionApp(config)
+
+	_, cookie := doSessionRequest(app, "POST", "/login", nil)
+	start := time.Now()
+
+	// Constant use keeps the session from idling out but not past its lifetime
+	for time.Since(start) < 150*time.Millisecond {
+		var w *httptest.ResponseRecorder
+		sent := time.Now()
+		w, cookie = doSessionRequest(app, "GET", "/me", cookie)
+		if sent.Sub(start) < 50*time.Millisecond && w.Body.String() == "" {
+			t.Fatalf("Expected session in use to be valid after %v", sent.Sub(start))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w, _ := doSessionRequest(app, "GET", "/me", cookie)
+	if w.Body.String() != "" {
+		t.Errorf("Expected session to expire after AbsoluteTimeout, got %q", w.Body.String())
+	}
+}
+
+func TestSessionTamperedCookie(t *testing.T) {
+	store, _ := NewCookieSessionStore([]byte("key"))
+	app := newSessionApp(NewSessionConfig(store))
+
+	w, _ := doSessionRequest(app, "GET", "/me", &http.Cookie{Name: "forge_session", Value: "tampered"})
+	if w.Code != 200 || w.Body.String() != "" {
+		t.Errorf("Expected tampered cookie to start a new session, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,136 @@
+package forge
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store is a key-value store with expiring entries shared by stateful
+// middleware such as the rate limiter. Implementations must make Update
+// atomic with respect to other writers of the same key.
+type Store interface {
+	// Get returns the value stored under key and whether it exists
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key; a ttl of zero means no expiration
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes key from the store
+	Delete(ctx context.Context, key string) error
+	// Update atomically replaces the value under key with the result of fn
+	Update(ctx context.Context, key string, ttl time.Duration, fn StoreUpdateFunc) error
+}
+
+// StoreUpdateFunc receives the current value of a key (nil if missing) and
+// returns the value to store. Returning an error aborts the update.
+type StoreUpdateFunc func(current []byte) ([]byte, error)
+
+// MemoryStore is a process-local Store. Expired entries are removed lazily
+// and by a periodic sweep during writes, so no background goroutine is needed.
+type MemoryStore struct {
+	entries    map[string]memoryEntry
+	mu         sync.Mutex
+	sweepEvery time.Duration
+	lastSweep  time.Time
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryStore creates a new in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries:    make(map[string]memoryEntry),
+		sweepEvery: time.Minute,
+		lastSweep:  time.Now(),
+	}
+}
+
+// Get returns the value stored under key
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.lookup(key, time.Now())
+	return value, ok, nil
+}
+
+// Set stores value under key
+func (s *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.store(key, value, ttl, time.Now())
+	return nil
+}
+
+// Delete removes key from the store
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// Update atomically replaces the value under key
+func (s *MemoryStore) Update(ctx context.Context, key string, ttl time.Duration, fn StoreUpdateFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	current, _ := s.lookup(key, now)
+	value, err := fn(current)
+	if err != nil {
+		return err
+	}
+
+	s.store(key, value, ttl, now)
+	return nil
+}
+
+// Len returns the number of live entries
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(time.Now())
+	return len(s.entries)
+}
+
+// lookup returns a live entry; callers must hold the lock
+func (s *MemoryStore) lookup(key string, now time.Time) ([]byte, bool) {
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !entry.expires.IsZero() && now.After(entry.expires) {
+		delete(s.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// store writes an entry; callers must hold the lock
+func (s *MemoryStore) store(key string, value []byte, ttl time.Duration, now time.Time) {
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expires = now.Add(ttl)
+	}
+	s.entries[key] = entry
+
+	if now.Sub(s.lastSweep) > s.sweepEvery {
+		s.sweep(now)
+	}
+}
+
+// sweep removes expired entries; callers must hold the lock
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if !entry.expires.IsZero() && now.After(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -1,37 +1,104 @@
 package forge
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 )
 
 // TemplateEngine represents the template engine
 type TemplateEngine struct {
 	templates map[string]*template.Template
-	baseDir   string
+	stamps    map[string]map[string]fileStamp // files each template was parsed from
+	fsys      fs.FS
+	root      string // Template directory inside fsys
+	baseDir   string // On-disk directory, empty for embedded templates
 	extension string
 	funcMap   template.FuncMap
 	mu        sync.RWMutex
 	devMode   bool
 }
 
+// TemplateConfig selects where templates are loaded from, so the same code
+// serves embedded templates in production and on-disk ones in development
+type TemplateConfig struct {
+	FS        fs.FS  // Templates shipped with the binary, e.g. an embed.FS
+	Root      string // Template directory inside FS
+	Dir       string // On-disk template directory, used in dev mode or when FS is nil
+	Extension string
+	DevMode   bool // Load from Dir and reload templates when they change
+}
+
+// NewTemplateConfig creates a template configuration for fsys, using the
+// same directory on disk in dev mode
+func NewTemplateConfig(fsys fs.FS, root, extension string) *TemplateConfig {
+	return &TemplateConfig{
+		FS:        fsys,
+		Root:      root,
+		Dir:       root,
+		Extension: extension,
+	}
+}
+
 // NewTemplateEngine creates a new template engine
 func NewTemplateEngine(baseDir, extension string) *TemplateEngine {
 	return &TemplateEngine{
 		templates: make(map[string]*template.Template),
+		stamps:    make(map[string]map[string]fileStamp),
+		fsys:      os.DirFS(baseDir),
+		root:      ".",
 		baseDir:   baseDir,
 		extension: extension,
-		funcMap:   make(template.FuncMap),
+		funcMap:   requestTemplateFuncs(),
+		devMode:   false,
+	}
+}
+
+// NewTemplateEngineFS creates a template engine reading the root directory
+// of fsys, such as an embed.FS
+func NewTemplateEngineFS(fsys fs.FS, root, extension string) *TemplateEngine {
+	return &TemplateEngine{
+		templates: make(map[string]*template.Template),
+		stamps:    make(map[string]map[string]fileStamp),
+		fsys:      fsys,
+		root:      path.Clean(root),
+		extension: extension,
+		funcMap:   requestTemplateFuncs(),
 		devMode:   false,
 	}
 }
 
-// SetDevMode enables/disables development mode (recompiles templates on each request)
+// NewTemplateEngineWithConfig creates a template engine from config. In dev
+// mode, or without an FS, templates are read from Dir on disk.
+func NewTemplateEngineWithConfig(config *TemplateConfig) *TemplateEngine {
+	if config.DevMode || config.FS == nil {
+		te := NewTemplateEngine(config.Dir, config.Extension)
+		te.devMode = config.DevMode
+		return te
+	}
+	return NewTemplateEngineFS(config.FS, config.Root, config.Extension)
+}
+
+// requestTemplateFuncs returns placeholders for functions whose values depend
+// on the request. Middleware overrides them per request with SetTemplateFunc.
+func requestTemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"cspNonce":  func() string { return "" },
+		"csrfToken": func() string { return "" },
+		"csrfField": func() template.HTML { return "" },
+	}
+}
+
+// SetDevMode enables/disables development mode (recompiles templates whose files changed)
 func (te *TemplateEngine) SetDevMode(enabled bool) {
 	te.devMode = enabled
 }
@@ -41,44 +108,72 @@ func (te *TemplateEngine) AddFunc(name string, fn interface{}) {
 	te.funcMap[name] = fn
 }
 
-// LoadTemplates loads all templates from the base directory
-func (te *TemplateEngine) LoadTemplates() error {
-	te.mu.Lock()
-	defer te.mu.Unlock()
+// layoutDirective matches the comment a page or layout starts with to
+// declare its layout, e.g. {{/* layout: layouts/base */}}
+var layoutDirective = regexp.MustCompile(`^\s*\{\{-?\s*/\*\s*layout:\s*(\S+)\s*\*/\s*-?\}\}`)
+
+// templateTree lists the template files under the template directory by their
+// names, the slash-separated relative paths without extension. Files under
+// a "partials" directory are parsed into every page and files under a
+// "layouts" directory wrap the pages that declare them; neither can be
+// rendered on its own.
+type templateTree struct {
+	paths    map[string]string // name -> path in the engine FS
+	pages    map[string]bool
+	layouts  map[string]bool
+	partials []string // in lexical order
+	dirs     map[string]fileStamp
+}
+
+// fileStamp identifies a version of a file or directory. Directories
+// change when entries are added, removed or renamed.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func newFileStamp(info fs.FileInfo) fileStamp {
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
 
-	pattern := filepath.Join(te.baseDir, "*."+te.extension)
-	templates, err := filepath.Glob(pattern)
+// LoadTemplates loads all templates from the base directory and its
+// subdirectories, replacing the ones loaded before
+func (te *TemplateEngine) LoadTemplates() error {
+	tree, err := te.scanTemplates()
 	if err != nil {
 		return err
 	}
 
-	for _, tmplPath := range templates {
-		name := strings.TrimSuffix(filepath.Base(tmplPath), "."+te.extension)
-		
-		// Parse the template file directly without New()
-		tmpl, err := template.New("").Funcs(te.funcMap).ParseFiles(tmplPath)
+	templates := make(map[string]*template.Template, len(tree.pages))
+	stamps := make(map[string]map[string]fileStamp, len(tree.pages))
+	for name := range tree.pages {
+		tmpl, files, err := te.parseTemplate(tree, name)
 		if err != nil {
 			return err
 		}
-		
-		// Get the actual template by filename
-		actualTmpl := tmpl.Lookup(filepath.Base(tmplPath))
-		if actualTmpl == nil {
-			return fmt.Errorf("template not found in parsed file: %s", tmplPath)
-		}
-		
-		te.templates[name] = actualTmpl
+		templates[name] = tmpl
+		stamps[name] = files
 	}
 
+	te.mu.Lock()
+	te.templates = templates
+	te.stamps = stamps
+	te.mu.Unlock()
 	return nil
 }
 
 // Render renders a template with data
 func (te *TemplateEngine) Render(w io.Writer, name string, data interface{}) error {
+	return te.RenderWithFuncs(w, name, data, nil)
+}
+
+// RenderWithFuncs renders a template with request-scoped functions that
+// override the ones registered on the engine
+func (te *TemplateEngine) RenderWithFuncs(w io.Writer, name string, data interface{}, funcs template.FuncMap) error {
 	if te.devMode {
-		// In dev mode, reload template on each request
+		// In dev mode, reload the template when its files changed
 		if err := te.loadSingleTemplate(name); err != nil {
-			return fmt.Errorf("failed to load template in dev mode: %v", err)
+			return fmt.Errorf("failed to load template in dev mode: %w", err)
 		}
 	}
 
@@ -90,37 +185,191 @@ func (te *TemplateEngine) Render(w io.Writer, name string, data interface{}) err
 		return fmt.Errorf("template not found: %s", name)
 	}
 
-	return tmpl.Execute(w, data)
+	// Templates are cloned so the cached ones are never executed and can
+	// always be cloned again with different request functions
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return err
+	}
+	if len(funcs) > 0 {
+		clone.Funcs(funcs)
+	}
+
+	if err := clone.Execute(w, data); err != nil {
+		return te.locateError(err)
+	}
+	return nil
 }
 
-// loadSingleTemplate loads a single template (used in dev mode)
+// loadSingleTemplate loads a single template (used in dev mode) unless the
+// files and directories it was parsed from are unchanged
 func (te *TemplateEngine) loadSingleTemplate(name string) error {
-	te.mu.Lock()
-	defer te.mu.Unlock()
-
-	tmplPath := filepath.Join(te.baseDir, name+"."+te.extension)
-	
-	// Check if file exists
-	if _, err := os.Stat(tmplPath); os.IsNotExist(err) {
-		return fmt.Errorf("template file not found: %s", tmplPath)
+	te.mu.RLock()
+	stamps, cached := te.stamps[name]
+	te.mu.RUnlock()
+	if cached && te.unchanged(stamps) {
+		return nil
 	}
-	
-	// Parse the template file directly without New()
-	tmpl, err := template.New("").Funcs(te.funcMap).ParseFiles(tmplPath)
+
+	tree, err := te.scanTemplates()
 	if err != nil {
 		return err
 	}
-	
-	// Get the actual template by filename
-	actualTmpl := tmpl.Lookup(filepath.Base(tmplPath))
-	if actualTmpl == nil {
-		return fmt.Errorf("template not found in parsed file: %s", tmplPath)
+	if !tree.pages[name] {
+		return fmt.Errorf("template file not found: %s", te.filePath(path.Join(te.root, name+"."+te.extension)))
 	}
-	
-	te.templates[name] = actualTmpl
+
+	tmpl, stamps, err := te.parseTemplate(tree, name)
+	if err != nil {
+		return err
+	}
+
+	te.mu.Lock()
+	te.templates[name] = tmpl
+	te.stamps[name] = stamps
+	te.mu.Unlock()
 	return nil
 }
 
+// unchanged reports whether every stamped file and directory is as recorded
+func (te *TemplateEngine) unchanged(stamps map[string]fileStamp) bool {
+	for file, stamp := range stamps {
+		info, err := fs.Stat(te.fsys, file)
+		if err != nil || !info.ModTime().Equal(stamp.modTime) || info.Size() != stamp.size {
+			return false
+		}
+	}
+	return true
+}
+
+// scanTemplates walks the template directory for template files. A missing
+// directory holds no templates.
+func (te *TemplateEngine) scanTemplates() (*templateTree, error) {
+	tree := &templateTree{
+		paths:   make(map[string]string),
+		pages:   make(map[string]bool),
+		layouts: make(map[string]bool),
+		dirs:    make(map[string]fileStamp),
+	}
+	suffix := "." + te.extension
+
+	err := fs.WalkDir(te.fsys, te.root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if file == te.root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			// Stamped before its entries are read, so later additions are seen
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			tree.dirs[file] = newFileStamp(info)
+			return nil
+		}
+		if !strings.HasSuffix(file, suffix) {
+			return nil
+		}
+
+		name := strings.TrimSuffix(file, suffix)
+		if te.root != "." {
+			name = strings.TrimPrefix(name, te.root+"/")
+		}
+		tree.paths[name] = file
+		dirs := strings.Split(name, "/")
+		switch dirs = dirs[:len(dirs)-1]; {
+		case containsString(dirs, "partials"):
+			tree.partials = append(tree.partials, name)
+		case containsString(dirs, "layouts"):
+			tree.layouts[name] = true
+		default:
+			tree.pages[name] = true
+		}
+		return nil
+	})
+	return tree, err
+}
+
+// parseTemplate parses a page together with its layouts and every partial.
+// Layouts are parsed outermost first so that the {{define}}s of inner
+// layouts and of the page override their {{block}}s; the returned template
+// executes the outermost layout, or the page when it has none. It also
+// returns the stamps of the files and directories the template depends on.
+func (te *TemplateEngine) parseTemplate(tree *templateTree, name string) (*template.Template, map[string]fileStamp, error) {
+	set := template.New("").Funcs(te.funcMap)
+	stamps := make(map[string]fileStamp, len(tree.dirs)+len(tree.partials)+1)
+	for dir, stamp := range tree.dirs {
+		stamps[dir] = stamp
+	}
+
+	for _, partial := range tree.partials {
+		content, err := te.readTemplateFile(tree.paths[partial], stamps)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := set.New(partial).Parse(string(content)); err != nil {
+			return nil, nil, te.locateError(err)
+		}
+	}
+
+	// Follow the layout declarations from the page outwards
+	chain := []string{name}
+	var sources []string
+	for {
+		current := chain[len(chain)-1]
+		content, err := te.readTemplateFile(tree.paths[current], stamps)
+		if err != nil {
+			return nil, nil, err
+		}
+		sources = append(sources, string(content))
+
+		match := layoutDirective.FindSubmatch(content)
+		if match == nil {
+			break
+		}
+		layout := string(match[1])
+		if !tree.layouts[layout] {
+			layout = "layouts/" + layout
+		}
+		if !tree.layouts[layout] {
+			return nil, nil, fmt.Errorf("layout %s declared by %s not found", match[1], te.filePath(tree.paths[current]))
+		}
+		if containsString(chain, layout) {
+			return nil, nil, fmt.Errorf("layout cycle in %s: %s", name, strings.Join(append(chain, layout), " -> "))
+		}
+		chain = append(chain, layout)
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		if _, err := set.New(chain[i]).Parse(sources[i]); err != nil {
+			return nil, nil, te.locateError(err)
+		}
+	}
+	return set.Lookup(chain[len(chain)-1]), stamps, nil
+}
+
+// readTemplateFile reads a template file, stamping it first so a change
+// made while it is parsed is caught by the next check
+func (te *TemplateEngine) readTemplateFile(file string, stamps map[string]fileStamp) ([]byte, error) {
+	info, err := fs.Stat(te.fsys, file)
+	if err != nil {
+		return nil, err
+	}
+	stamps[file] = newFileStamp(info)
+	return fs.ReadFile(te.fsys, file)
+}
+
+// filePath returns the path of a template file for messages, on disk when
+// the engine reads from a directory
+func (te *TemplateEngine) filePath(file string) string {
+	if te.baseDir == "" {
+		return file
+	}
+	return filepath.Join(te.baseDir, filepath.FromSlash(file))
+}
+
 // Template middleware for Forge
 func (f *Forge) SetTemplateEngine(engine *TemplateEngine) {
 	f.templateEngine = engine
@@ -136,9 +385,12 @@ func (c *Context) Render(status int, name string, data interface{}) error {
 	// Get the template engine from the context or forge instance
 	if engine := c.Get("template_engine"); engine != nil {
 		te := engine.(*TemplateEngine)
+		if te.devMode {
+			return te.renderDev(c, status, name, data)
+		}
 		c.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
 		c.Response.WriteHeader(status)
-		return te.Render(c.Response, name, data)
+		return te.RenderWithFuncs(c.Response, name, data, c.getTemplateFuncs())
 	}
 	
 	// Fallback if no template engine is set
@@ -148,6 +400,28 @@ func (c *Context) Render(status int, name string, data interface{}) error {
 	return err
 }
 
+// SetTemplateFunc sets a template function for the current request only,
+// overriding the engine function of the same name during c.Render
+func (c *Context) SetTemplateFunc(name string, fn interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.locals == nil {
+		c.locals = make(map[string]interface{})
+	}
+	funcs, _ := c.locals["template_funcs"].(template.FuncMap)
+	if funcs == nil {
+		funcs = make(template.FuncMap)
+		c.locals["template_funcs"] = funcs
+	}
+	funcs[name] = fn
+}
+
+// getTemplateFuncs returns the request-scoped template functions
+func (c *Context) getTemplateFuncs() template.FuncMap {
+	funcs, _ := c.Get("template_funcs").(template.FuncMap)
+	return funcs
+}
+
 // Built-in template functions
 func DefaultTemplateFuncs() template.FuncMap {
 	return template.FuncMap{
@@ -0,0 +1,118 @@
+package forge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// templateErrorLocation matches the location html/template puts in front
+// of parse and execution errors, e.g. "template: admin/index:12: ..."
+var templateErrorLocation = regexp.MustCompile(`(?s)^(?:html/)?template: ?([^:]+):(\d+):(?:\d+:)? ?(.*)$`)
+
+// TemplateError is a template parse or execution error located in the file
+// it comes from
+type TemplateError struct {
+	Name    string // Template file name, e.g. "partials/nav"
+	File    string
+	Line    int
+	Message string
+	Err     error // The error reported by html/template
+
+	source string // Path in the engine FS
+}
+
+// Error returns the message prefixed with the file and line
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+// Unwrap returns the html/template error
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// locateError turns an html/template error into a TemplateError when it
+// points into one of the engine's files
+func (te *TemplateEngine) locateError(err error) error {
+	match := templateErrorLocation.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+	source := path.Join(te.root, match[1]+"."+te.extension)
+	if _, statErr := fs.Stat(te.fsys, source); statErr != nil {
+		return err
+	}
+	line, _ := strconv.Atoi(match[2])
+	return &TemplateError{
+		Name:    match[1],
+		File:    te.filePath(source),
+		Line:    line,
+		Message: match[3],
+		Err:     err,
+		source:  source,
+	}
+}
+
+// renderDev renders into a buffer so that a template failing in dev mode
+// is replaced by an error page showing where it failed
+func (te *TemplateEngine) renderDev(c *Context, status int, name string, data interface{}) error {
+	var buf bytes.Buffer
+	if err := te.RenderWithFuncs(&buf, name, data, c.getTemplateFuncs()); err != nil {
+		var tmplErr *TemplateError
+		if !errors.As(err, &tmplErr) {
+			return err
+		}
+		log.Printf("Template error: %v", tmplErr)
+		c.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
+		c.Response.WriteHeader(500)
+		return devErrorPage.Execute(c.Response, te.errorPageData(tmplErr))
+	}
+
+	c.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
+	c.Response.WriteHeader(status)
+	_, err := buf.WriteTo(c.Response)
+	return err
+}
+
+// sourceLine is a line of template source shown on the error page
+type sourceLine struct {
+	Number  int
+	Text    string
+	Current bool
+}
+
+// errorPageData returns the error with the source lines around it
+func (te *TemplateEngine) errorPageData(err *TemplateError) map[string]interface{} {
+	var lines []sourceLine
+	if content, readErr := fs.ReadFile(te.fsys, err.source); readErr == nil {
+		all := strings.Split(string(content), "\n")
+		for n := err.Line - 5; n <= err.Line+5; n++ {
+			if n >= 1 && n <= len(all) {
+				lines = append(lines, sourceLine{Number: n, Text: all[n-1], Current: n == err.Line})
+			}
+		}
+	}
+	return map[string]interface{}{"Error": err, "Lines": lines}
+}
+
+// devErrorPage is shown instead of a failing template in dev mode
+var devErrorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Template error</title></head>
+<body>
+<h1>Template error</h1>
+<p><strong>{{.Error.File}}:{{.Error.Line}}</strong></p>
+<pre>{{.Error.Message}}</pre>
+{{if .Lines}}<pre>{{range .Lines}}{{if .Current}}<mark>{{printf "%4d" .Number}} | {{.Text}}</mark>{{else}}{{printf "%4d" .Number}} | {{.Text}}{{end}}
+{{end}}</pre>{{end}}
+</body>
+</html>
+`))
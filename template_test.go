@@ -0,0 +1,236 @@
+package forge
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// writeTemplates creates template files from a map of relative paths
+func writeTemplates(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func renderString(t *testing.T, engine *TemplateEngine, name string, data interface{}) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := engine.Render(&buf, name, data); err != nil {
+		t.Fatalf("Render %s failed: %v", name, err)
+	}
+	return buf.String()
+}
+
+func TestTemplateLayoutsPartialsAndBlocks(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplates(t, dir, map[string]string{
+		"layouts/base.html": `<title>{{block "title" .}}Forge{{end}}</title>` +
+			`{{template "header" .}}<main>{{block "content" .}}{{end}}</main>{{template "partials/footer"}}`,
+		"layouts/admin.html":     `{{/* layout: base */}}{{define "content"}}<nav>admin</nav>{{block "panel" .}}{{end}}{{end}}`,
+		"partials/header.html":   `{{define "header"}}<header>{{.User}}</header>{{end}}`,
+		"partials/footer.html":   `<footer>footer</footer>`,
+		"index.html":             `{{/* layout: layouts/base */}}{{define "content"}}home{{end}}`,
+		"about.html":             `{{/* layout: base */}}{{define "title"}}About{{end}}{{define "content"}}about{{end}}`,
+		"admin/users/index.html": `{{/* layout: admin */}}{{define "panel"}}users of {{.User}}{{end}}`,
+		"plain.html":             `<p>{{template "header" .}}</p>`,
+	})
+
+	engine := NewTemplateEngine(dir, "html")
+	if err := engine.LoadTemplates(); err != nil {
+		t.Fatalf("LoadTemplates failed: %v", err)
+	}
+	data := map[string]string{"User": "ana"}
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"index", "<title>Forge</title><header>ana</header><main>home</main><footer>footer</footer>"},
+		{"about", "<title>About</title><header>ana</header><main>about</main><footer>footer</footer>"},
+		{"admin/users/index", "<title>Forge</title><header>ana</header><main><nav>admin</nav>users of ana</main><footer>footer</footer>"},
+		{"plain", "<p><header>ana</header></p>"},
+	}
+	for _, tt := range tests {
+		if got := renderString(t, engine, tt.name, data); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+
+	for _, name := range []string{"layouts/base", "partials/header", "users/index"} {
+		if err := engine.Render(&bytes.Buffer{}, name, data); err == nil {
+			t.Errorf("expected %s not to be renderable", name)
+		}
+	}
+}
+
+func TestTemplateLayoutErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplates(t, dir, map[string]string{
+		"layouts/a.html": `{{/* layout: b */}}a`,
+		"layouts/b.html": `{{/* layout: a */}}b`,
+		"page.html":      `{{/* layout: a */}}page`,
+	})
+	err := NewTemplateEngine(dir, "html").LoadTemplates()
+	if err == nil || !strings.Contains(err.Error(), "layout cycle") {
+		t.Errorf("expected layout cycle error, got %v", err)
+	}
+
+	writeTemplates(t, dir, map[string]string{"page.html": `{{/* layout: missing */}}page`})
+	err = NewTemplateEngine(dir, "html").LoadTemplates()
+	if err == nil || !strings.Contains(err.Error(), "layout missing") {
+		t.Errorf("expected missing layout error, got %v", err)
+	}
+}
+
+func TestTemplateDevModeReloadsPartials(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplates(t, dir, map[string]string{
+		"partials/greeting.html": `{{define "greeting"}}hello{{end}}`,
+		"page.html":              `{{template "greeting"}}`,
+	})
+
+	engine := NewTemplateEngine(dir, "html")
+	engine.SetDevMode(true)
+	if got := renderString(t, engine, "page", nil); got != "hello" {
+		t.Fatalf("expected hello, got %q", got)
+	}
+
+	writeTemplates(t, dir, map[string]string{"partials/greeting.html": `{{define "greeting"}}bye{{end}}`})
+	if got := renderString(t, engine, "page", nil); got != "bye" {
+		t.Errorf("expected edited partial to be picked up, got %q", got)
+	}
+}
+
+func TestTemplateEngineFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"web/templates/layouts/base.html": {Data: []byte(`<body>{{block "content" .}}{{end}}</body>`)},
+		"web/templates/partials/nav.html": {Data: []byte(`{{define "nav"}}<nav></nav>{{end}}`)},
+		"web/templates/admin/index.html":  {Data: []byte(`{{/* layout: base */}}{{define "content"}}{{template "nav"}}{{.}}{{end}}`)},
+		"web/other.html":                  {Data: []byte(`outside root`)},
+	}
+
+	engine := NewTemplateEngineFS(fsys, "web/templates/", "html")
+	if err := engine.LoadTemplates(); err != nil {
+		t.Fatalf("LoadTemplates failed: %v", err)
+	}
+	if got := renderString(t, engine, "admin/index", "embedded"); got != "<body><nav></nav>embedded</body>" {
+		t.Errorf("unexpected output %q", got)
+	}
+	if err := engine.Render(&bytes.Buffer{}, "other", nil); err == nil {
+		t.Error("expected files outside the root not to be loaded")
+	}
+
+	engine.SetDevMode(true)
+	if got := renderString(t, engine, "admin/index", "dev"); got != "<body><nav></nav>dev</body>" {
+		t.Errorf("unexpected output in dev mode %q", got)
+	}
+}
+
+func TestTemplateConfigSwitchesToDisk(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplates(t, dir, map[string]string{"page.html": `disk`})
+	fsys := fstest.MapFS{"templates/page.html": {Data: []byte(`embedded`)}}
+
+	config := NewTemplateConfig(fsys, "templates", "html")
+	config.Dir = dir
+
+	engine := NewTemplateEngineWithConfig(config)
+	if err := engine.LoadTemplates(); err != nil {
+		t.Fatalf("LoadTemplates failed: %v", err)
+	}
+	if got := renderString(t, engine, "page", nil); got != "embedded" {
+		t.Errorf("expected embedded template in production, got %q", got)
+	}
+
+	config.DevMode = true
+	engine = NewTemplateEngineWithConfig(config)
+	if got := renderString(t, engine, "page", nil); got != "disk" {
+		t.Errorf("expected on-disk template in dev mode, got %q", got)
+	}
+	writeTemplates(t, dir, map[string]string{"page.html": `edited`})
+	if got := renderString(t, engine, "page", nil); got != "edited" {
+		t.Errorf("expected edited template in dev mode, got %q", got)
+	}
+}
+
+func TestTemplateDevModeCachesUnchangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplates(t, dir, map[string]string{
+		"layouts/base.html": `[{{block "content" .}}{{end}}]`,
+		"page.html":         `{{/* layout: base */}}{{define "content"}}page{{end}}`,
+	})
+
+	engine := NewTemplateEngine(dir, "html")
+	engine.SetDevMode(true)
+	cached := func() *template.Template {
+		engine.mu.RLock()
+		defer engine.mu.RUnlock()
+		return engine.templates["page"]
+	}
+
+	renderString(t, engine, "page", nil)
+	first := cached()
+	if got := renderString(t, engine, "page", nil); got != "[page]" || cached() != first {
+		t.Fatalf("expected unchanged template to be reused, got %q", got)
+	}
+
+	writeTemplates(t, dir, map[string]string{"layouts/base.html": `({{block "content" .}}{{end}})`})
+	if got := renderString(t, engine, "page", nil); got != "(page)" || cached() == first {
+		t.Errorf("expected edited layout to be picked up, got %q", got)
+	}
+
+	// A new partial overriding a block is found through its directory
+	second := cached()
+	writeTemplates(t, dir, map[string]string{"partials/content.html": `{{define "content"}}partial{{end}}`})
+	renderString(t, engine, "page", nil)
+	if cached() == second {
+		t.Error("expected new partial to trigger a reparse")
+	}
+}
+
+func TestTemplateDevErrorPage(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplates(t, dir, map[string]string{
+		"partials/nav.html": "{{define \"nav\"}}\n<nav>\n{{.User | nope}}\n</nav>{{end}}",
+		"page.html":         `{{template "nav" .}}`,
+	})
+
+	engine := NewTemplateEngine(dir, "html")
+	engine.SetDevMode(true)
+	app := New()
+	app.SetTemplateEngine(engine)
+	app.GET("/", func(c *Context) error { return c.Render(200, "page", nil) })
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	body := w.Body.String()
+	if w.Code != 500 || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("expected 500 HTML error page, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	location := filepath.Join(dir, "partials", "nav.html") + ":3"
+	if !strings.Contains(body, location) || !strings.Contains(body, `function &#34;nope&#34; not defined`) {
+		t.Errorf("expected error location %s in page, got %s", location, body)
+	}
+	if !strings.Contains(body, "<mark>   3 | {{.User | nope}}</mark>") {
+		t.Errorf("expected failing line to be highlighted, got %s", body)
+	}
+
+	var tmplErr *TemplateError
+	if err := engine.Render(&bytes.Buffer{}, "page", nil); !errors.As(err, &tmplErr) || tmplErr.Name != "partials/nav" || tmplErr.Line != 3 {
+		t.Errorf("expected TemplateError for partials/nav:3, got %v", err)
+	}
+}
@@ -1,70 +1,314 @@
 package forge
 
 import (
+	"bufio"
+	"compress/flate"
 	"crypto/sha1"
 	"encoding/base64"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 )
 
 // WebSocket constants
 const (
 	websocketMagicString = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	defaultReadLimit     = 1 << 20
+	defaultSendQueueSize = 64
+	closeGracePeriod     = 5 * time.Second
 )
 
+// WebSocket message types (RFC 6455 opcodes)
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// WebSocket close codes (RFC 6455 section 7.4.1)
+const (
+	CloseNormalClosure       = 1000
+	CloseGoingAway           = 1001
+	CloseProtocolError       = 1002
+	CloseUnsupportedData     = 1003
+	CloseNoStatusReceived    = 1005
+	CloseAbnormalClosure     = 1006
+	CloseInvalidPayload      = 1007
+	ClosePolicyViolation     = 1008
+	CloseMessageTooBig       = 1009
+	CloseInternalServerError = 1011
+)
+
+// WebSocket errors
+var (
+	ErrWebSocketClosed    = errors.New("websocket: connection closed")
+	ErrWebSocketQueueFull = errors.New("websocket: send queue full")
+)
+
+// CloseError reports the close code and reason that ended a connection
+type CloseError struct {
+	Code int
+	Text string
+}
+
+// Error returns the close code and reason
+func (e *CloseError) Error() string {
+	if e.Text == "" {
+		return fmt.Sprintf("websocket: close %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: close %d: %s", e.Code, e.Text)
+}
+
 // WebSocketHandler represents a WebSocket handler function
 type WebSocketHandler func(*WebSocketConnection)
 
-// WebSocketConnection represents a WebSocket connection
+// WebSocketConfig represents WebSocket upgrade configuration
+type WebSocketConfig struct {
+	CheckOrigin    func(r *http.Request) bool                 // nil allows requests without Origin or from the same host
+	Subprotocols   []string                                   // Supported subprotocols in order of preference
+	ResponseHeader func(c *Context, header http.Header) error // Adds handshake response headers; an error aborts the upgrade
+	Middleware     []MiddlewareFunc                           // Runs before the upgrade, e.g. JWT auth with a query token
+	ReadLimit      int64                                      // Maximum message size in bytes (0 uses 1 MiB)
+	SendQueueSize  int                                        // Outgoing messages buffered per connection (0 uses 64)
+	WriteTimeout   time.Duration                              // Deadline for writing one frame (0 disables)
+	PingInterval   time.Duration                              // Interval between server pings (0 disables)
+	PongTimeout    time.Duration                              // Closes the connection when nothing is read for this long (0 disables)
+
+	// permessage-deflate (RFC 7692). Context takeover compresses streams of
+	// similar messages better but keeps a compressor (about 1 MiB at high
+	// levels) and a 32 KiB window per connection.
+	EnableCompression       bool
+	CompressionLevel        int  // compress/flate level (0 uses flate.BestSpeed)
+	CompressionThreshold    int  // Smaller messages are sent uncompressed
+	ServerNoContextTakeover bool // Reset the compressor after every message
+	ClientNoContextTakeover bool // Ask clients to reset theirs, so no window is kept for reading
+}
+
+// NewWebSocketConfig creates a WebSocket configuration accepting same-origin
+// requests, pinging every 30s and dropping peers silent for 60s
+func NewWebSocketConfig() *WebSocketConfig {
+	return &WebSocketConfig{
+		ReadLimit:     defaultReadLimit,
+		SendQueueSize: defaultSendQueueSize,
+		WriteTimeout:  10 * time.Second,
+		PingInterval:  30 * time.Second,
+		PongTimeout:   60 * time.Second,
+
+		CompressionLevel:     flate.BestSpeed,
+		CompressionThreshold: 512,
+	}
+}
+
+// WebSocketConnection represents a WebSocket connection on a hijacked
+// net.Conn. Reads must come from a single goroutine. Writes from any
+// goroutine are queued and written in order by a per-connection pump.
 type WebSocketConnection struct {
-	conn   http.ResponseWriter
-	req    *http.Request
-	closed bool
+	id          string
+	conn        net.Conn
+	reader      *bufio.Reader
+	req         *http.Request
+	subprotocol string
+	userID      string
+	realIP      string
+
+	readLimit    int64
+	writeTimeout time.Duration
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+
+	compressor   *wsCompressor   // nil unless permessage-deflate was negotiated
+	decompressor *wsDecompressor // nil unless permessage-deflate was negotiated
+
+	send      chan wsOutgoing
+	closeSent bool // only touched by the write pump
+	closed    atomic.Bool
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// wsOutgoing is a frame waiting in the send queue
+type wsOutgoing struct {
+	opcode  int
+	payload []byte
+	result  chan error // nil for fire-and-forget sends
 }
 
 // WebSocket upgrade middleware
 func (f *Forge) WebSocket(pattern string, handler WebSocketHandler) {
-	f.GET(pattern, func(c *Context) error {
-		return f.upgradeWebSocket(c, handler)
-	})
+	f.WebSocketWithConfig(pattern, NewWebSocketConfig(), handler)
 }
 
-// upgradeWebSocket handles the WebSocket upgrade process
-func (f *Forge) upgradeWebSocket(c *Context, handler WebSocketHandler) error {
-	// Check if it's a WebSocket upgrade request
+// WebSocketWithConfig registers a WebSocket route. The config middleware
+// runs before the handshake, so it can reject the request with a normal
+// HTTP response.
+func (f *Forge) WebSocketWithConfig(pattern string, config *WebSocketConfig, handler WebSocketHandler) {
+	f.addRoute("GET", pattern, func(c *Context) error {
+		return f.upgradeWebSocket(c, config, handler)
+	}, config.Middleware...)
+}
+
+// upgradeWebSocket validates the handshake, hijacks the connection and runs
+// the handler. The connection is closed when the handler returns.
+func (f *Forge) upgradeWebSocket(c *Context, config *WebSocketConfig, handler WebSocketHandler) error {
 	if !IsWebSocketUpgrade(c.Request) {
-		return c.String(400, "Bad Request: Not a WebSocket upgrade")
+		return NewHTTPError(http.StatusBadRequest, "not a WebSocket upgrade")
+	}
+	if c.Request.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.Header("Sec-WebSocket-Version", "13")
+		return NewHTTPError(http.StatusUpgradeRequired, "unsupported WebSocket version")
 	}
-
-	// Get the WebSocket key
 	key := c.Request.Header.Get("Sec-WebSocket-Key")
-	if key == "" {
-		return c.String(400, "Bad Request: Missing Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return NewHTTPError(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
 	}
 
-	// Generate accept key
-	acceptKey := GenerateAcceptKey(key)
+	checkOrigin := config.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(c.Request) {
+		return NewHTTPError(http.StatusForbidden, "origin not allowed")
+	}
 
-	// Set WebSocket headers
-	c.Response.Header().Set("Upgrade", "websocket")
-	c.Response.Header().Set("Connection", "Upgrade")
-	c.Response.Header().Set("Sec-WebSocket-Accept", acceptKey)
-	c.Response.WriteHeader(101) // Switching Protocols
+	// Headers already set by middleware (cookies, ...) go out with the 101
+	header := c.Response.Header()
+	subprotocol := negotiateSubprotocol(c.Request, config.Subprotocols)
+	if subprotocol != "" {
+		header.Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+	var deflate *deflateParams
+	if config.EnableCompression {
+		var extension string
+		if deflate, extension = negotiateDeflate(c.Request, config); deflate != nil {
+			header.Set("Sec-WebSocket-Extensions", extension)
+		}
+	}
+	if config.ResponseHeader != nil {
+		if err := config.ResponseHeader(c, header); err != nil {
+			return err
+		}
+	}
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", GenerateAcceptKey(key))
+
+	id, err := randomToken(12)
+	if err != nil {
+		return err
+	}
 
-	// Create WebSocket connection
-	wsConn := &WebSocketConnection{
-		conn: c.Response,
-		req:  c.Request,
+	netConn, rw, err := http.NewResponseController(c.Response).Hijack()
+	if err != nil {
+		return fmt.Errorf("websocket: %w", err)
+	}
+	// Server read/write timeouts do not apply to long-lived connections
+	netConn.SetDeadline(time.Time{})
+
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	header.Write(rw)
+	rw.WriteString("\r\n")
+	if err := rw.Flush(); err != nil {
+		netConn.Close()
+		return nil
 	}
 
-	// Handle the WebSocket connection
-	handler(wsConn)
+	ws := newWebSocketConnection(netConn, rw.Reader, c.Request, config)
+	ws.id = id
+	ws.subprotocol = subprotocol
+	ws.userID = GetUserID(c)
+	ws.realIP = c.RealIP()
+	if deflate != nil {
+		ws.enableDeflate(deflate, config)
+	}
+	go ws.writePump()
+	defer ws.Close()
 
+	handler(ws)
 	return nil
 }
 
+// newWebSocketConnection wraps a hijacked connection, applying defaults
+// for unset limits
+func newWebSocketConnection(conn net.Conn, reader *bufio.Reader, req *http.Request, config *WebSocketConfig) *WebSocketConnection {
+	readLimit := config.ReadLimit
+	if readLimit <= 0 {
+		readLimit = defaultReadLimit
+	}
+	queueSize := config.SendQueueSize
+	if queueSize <= 0 {
+		queueSize = defaultSendQueueSize
+	}
+	return &WebSocketConnection{
+		conn:         conn,
+		reader:       reader,
+		req:          req,
+		readLimit:    readLimit,
+		writeTimeout: config.WriteTimeout,
+		pingInterval: config.PingInterval,
+		pongTimeout:  config.PongTimeout,
+		send:         make(chan wsOutgoing, queueSize),
+		done:         make(chan struct{}),
+	}
+}
+
+// sameOrigin accepts requests without an Origin header (non-browser
+// clients) and browser requests whose origin host matches the Host header
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// negotiateSubprotocol picks the first supported subprotocol the client offers
+func negotiateSubprotocol(r *http.Request, supported []string) string {
+	offered := make(map[string]bool)
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(value, ",") {
+			offered[strings.TrimSpace(protocol)] = true
+		}
+	}
+	for _, protocol := range supported {
+		if offered[protocol] {
+			return protocol
+		}
+	}
+	return ""
+}
+
+// enableDeflate sets up permessage-deflate with the negotiated parameters
+func (ws *WebSocketConnection) enableDeflate(params *deflateParams, config *WebSocketConfig) {
+	level := config.CompressionLevel
+	if level == 0 {
+		level = flate.BestSpeed
+	}
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		level = flate.DefaultCompression
+	}
+	ws.compressor = &wsCompressor{
+		level:     level,
+		takeover:  !params.serverNoContextTakeover,
+		threshold: config.CompressionThreshold,
+	}
+	ws.decompressor = &wsDecompressor{takeover: !params.clientNoContextTakeover}
+}
+
 // IsWebSocketUpgrade checks if the request is a WebSocket upgrade
 func IsWebSocketUpgrade(r *http.Request) bool {
 	return strings.ToLower(r.Header.Get("Upgrade")) == "websocket" &&
@@ -78,47 +322,417 @@ func GenerateAcceptKey(key string) string {
 	return base64.StdEncoding.EncodeToString(h.Sum(nil))
 }
 
+// ID returns a random identifier unique to the connection
+func (ws *WebSocketConnection) ID() string {
+	return ws.id
+}
+
+// Request returns the HTTP request that opened the connection
+func (ws *WebSocketConnection) Request() *http.Request {
+	return ws.req
+}
+
+// Subprotocol returns the negotiated subprotocol ("" if none)
+func (ws *WebSocketConnection) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// UserID returns the user ID set by authentication middleware before the
+// upgrade ("" for anonymous connections)
+func (ws *WebSocketConnection) UserID() string {
+	return ws.userID
+}
+
+// RealIP returns the client IP resolved at upgrade time, honoring
+// forwarding headers from trusted proxies like Context.RealIP
+func (ws *WebSocketConnection) RealIP() string {
+	return ws.realIP
+}
+
+// IsClosed reports whether the connection has been closed
+func (ws *WebSocketConnection) IsClosed() bool {
+	return ws.closed.Load()
+}
+
+// Done returns a channel closed when the connection closes
+func (ws *WebSocketConnection) Done() <-chan struct{} {
+	return ws.done
+}
+
+// ReadMessage reads the next text or binary message, reassembling
+// fragments. Pings are answered and a close frame is echoed before a
+// *CloseError is returned. Protocol violations close the connection.
+func (ws *WebSocketConnection) ReadMessage() (int, []byte, error) {
+	messageType := 0
+	compressed := false
+	var message []byte
+
+	for {
+		// Any frame, pongs included, proves the peer is alive
+		if ws.pongTimeout > 0 {
+			ws.conn.SetReadDeadline(time.Now().Add(ws.pongTimeout))
+		}
+		fin, rsv1, opcode, payload, err := ws.readFrame(ws.readLimit - int64(len(message)))
+		if err != nil {
+			return 0, nil, ws.fail(err)
+		}
+		// Only the first frame of a data message may carry the compression bit
+		if rsv1 && (opcode != TextMessage && opcode != BinaryMessage) {
+			return 0, nil, ws.fail(&CloseError{CloseProtocolError, "unexpected compressed frame"})
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := ws.enqueue(wsOutgoing{opcode: PongMessage, payload: payload}, true); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			return 0, nil, ws.handleClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, ws.fail(&CloseError{CloseProtocolError, "expected continuation frame"})
+			}
+			messageType = opcode
+			compressed = rsv1
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, ws.fail(&CloseError{CloseProtocolError, "unexpected continuation frame"})
+			}
+		default:
+			return 0, nil, ws.fail(&CloseError{CloseProtocolError, "unknown opcode"})
+		}
+
+		message = append(message, payload...)
+		if fin {
+			if compressed {
+				if message, err = ws.decompressor.decompress(message, ws.readLimit); err != nil {
+					return 0, nil, ws.fail(err)
+				}
+			}
+			if messageType == TextMessage && !utf8.Valid(message) {
+				return 0, nil, ws.fail(&CloseError{CloseInvalidPayload, "invalid UTF-8"})
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+// WriteMessage sends a single-frame message and waits until it is written.
+// Text, binary, ping and pong messages are accepted; use CloseWithReason
+// to close. It blocks while the send queue is full.
+func (ws *WebSocketConnection) WriteMessage(messageType int, data []byte) error {
+	if err := checkMessageType(messageType, data); err != nil {
+		return err
+	}
+	return ws.sendAndWait(messageType, data)
+}
+
+// SendAsync queues a message without waiting for it to be written. It
+// returns ErrWebSocketQueueFull instead of blocking on a slow client. The
+// data must not be modified after the call.
+func (ws *WebSocketConnection) SendAsync(messageType int, data []byte) error {
+	if err := checkMessageType(messageType, data); err != nil {
+		return err
+	}
+	return ws.enqueue(wsOutgoing{opcode: messageType, payload: data}, false)
+}
+
 // Send sends a text message to the WebSocket client
 func (ws *WebSocketConnection) Send(message string) error {
-	if ws.closed {
-		return fmt.Errorf("connection is closed")
+	return ws.WriteMessage(TextMessage, []byte(message))
+}
+
+// Close sends a normal closure and closes the connection
+func (ws *WebSocketConnection) Close() error {
+	return ws.CloseWithReason(CloseNormalClosure, "")
+}
+
+// CloseWithReason sends a close frame with a code and reason, then closes
+// the connection. Closing twice is a no-op.
+func (ws *WebSocketConnection) CloseWithReason(code int, reason string) error {
+	if ws.closed.Load() {
+		return nil
+	}
+	err := ws.sendAndWait(CloseMessage, closePayload(code, reason))
+	if errors.Is(err, ErrWebSocketClosed) {
+		err = nil
+	}
+	ws.closeConn()
+	return err
+}
+
+// checkMessageType validates a message passed to the public write methods
+func checkMessageType(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+	case PingMessage, PongMessage:
+		if len(data) > 125 {
+			return errors.New("websocket: control frame payload too long")
+		}
+	default:
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return nil
+}
+
+// enqueue adds a frame to the send queue. Without block it fails fast
+// when the queue is full.
+func (ws *WebSocketConnection) enqueue(msg wsOutgoing, block bool) error {
+	if ws.closed.Load() {
+		return ErrWebSocketClosed
+	}
+	if block {
+		select {
+		case ws.send <- msg:
+			return nil
+		case <-ws.done:
+			return ErrWebSocketClosed
+		}
+	}
+	select {
+	case ws.send <- msg:
+		return nil
+	case <-ws.done:
+		return ErrWebSocketClosed
+	default:
+		return ErrWebSocketQueueFull
+	}
+}
+
+// sendAndWait queues a frame and waits for the pump to write it. Close
+// frames give up after a grace period so a stuck peer cannot block Close.
+func (ws *WebSocketConnection) sendAndWait(opcode int, payload []byte) error {
+	result := make(chan error, 1)
+	if err := ws.enqueue(wsOutgoing{opcode: opcode, payload: payload, result: result}, true); err != nil {
+		return err
+	}
+	var timeout <-chan time.Time
+	if opcode == CloseMessage {
+		timer := time.NewTimer(closeGracePeriod)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-ws.done:
+		return ErrWebSocketClosed
+	case <-timeout:
+		return ErrWebSocketClosed
+	}
+}
+
+// writePump writes queued frames and pings until the connection closes.
+// A failed write closes the connection.
+func (ws *WebSocketConnection) writePump() {
+	var ping <-chan time.Time
+	if ws.pingInterval > 0 {
+		ticker := time.NewTicker(ws.pingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	for {
+		select {
+		case <-ws.done:
+			return
+		case msg := <-ws.send:
+			err := ws.writeFrame(msg.opcode, msg.payload)
+			if msg.result != nil {
+				msg.result <- err
+			}
+			if err != nil && !errors.Is(err, ErrWebSocketClosed) {
+				ws.closeConn()
+				return
+			}
+		case <-ping:
+			if err := ws.writeFrame(PingMessage, nil); err != nil && !errors.Is(err, ErrWebSocketClosed) {
+				ws.closeConn()
+				return
+			}
+		}
+	}
+}
+
+// readFrame reads one client frame. limit bounds data frame payloads.
+// RSV1 marks compressed messages when permessage-deflate was negotiated.
+func (ws *WebSocketConnection) readFrame(limit int64) (fin, rsv1 bool, opcode int, payload []byte, err error) {
+	var header [8]byte
+	if _, err = io.ReadFull(ws.reader, header[:2]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	rsv1 = header[0]&0x40 != 0
+	opcode = int(header[0] & 0x0f)
+	if header[0]&0x30 != 0 || (rsv1 && ws.decompressor == nil) {
+		err = &CloseError{CloseProtocolError, "reserved bits set"}
+		return
+	}
+	if header[1]&0x80 == 0 {
+		err = &CloseError{CloseProtocolError, "client frame not masked"}
+		return
+	}
+
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		if _, err = io.ReadFull(ws.reader, header[:2]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(header[:2]))
+	case 127:
+		if _, err = io.ReadFull(ws.reader, header[:8]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(header[:8]))
+		if length < 0 {
+			err = &CloseError{CloseProtocolError, "invalid frame length"}
+			return
+		}
+	}
+
+	if opcode >= CloseMessage {
+		if !fin || length > 125 {
+			err = &CloseError{CloseProtocolError, "invalid control frame"}
+			return
+		}
+	} else if length > limit {
+		err = &CloseError{CloseMessageTooBig, "message too big"}
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(ws.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(ws.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// writeFrame writes one unmasked server frame; only the write pump calls it
+func (ws *WebSocketConnection) writeFrame(opcode int, payload []byte) error {
+	if ws.closeSent || ws.closed.Load() {
+		return ErrWebSocketClosed
+	}
+
+	b0 := 0x80 | byte(opcode)
+	if ws.compressor != nil && ws.compressor.shouldCompress(opcode, payload) {
+		compressed, err := ws.compressor.compress(payload)
+		if err != nil {
+			return err
+		}
+		payload = compressed
+		b0 |= 0x40
 	}
 
-	// Simple text frame (this is a basic implementation)
-	frame := []byte{0x81} // FIN=1, opcode=1 (text)
-	
-	msgLen := len(message)
-	if msgLen < 126 {
-		frame = append(frame, byte(msgLen))
-	} else if msgLen < 65536 {
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, b0)
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, byte(length))
+	case length < 65536:
 		frame = append(frame, 126)
-		frame = append(frame, byte(msgLen>>8), byte(msgLen&0xFF))
-	} else {
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
 		frame = append(frame, 127)
-		for i := 7; i >= 0; i-- {
-			frame = append(frame, byte(msgLen>>(i*8)))
-		}
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	frame = append(frame, payload...)
+
+	if opcode == CloseMessage {
+		ws.closeSent = true
+	}
+	if ws.writeTimeout > 0 {
+		ws.conn.SetWriteDeadline(time.Now().Add(ws.writeTimeout))
 	}
-	
-	frame = append(frame, []byte(message)...)
-	
 	_, err := ws.conn.Write(frame)
 	return err
 }
 
-// Close closes the WebSocket connection
-func (ws *WebSocketConnection) Close() error {
-	if ws.closed {
-		return nil
+// handleClose validates a received close frame, echoes it and closes
+func (ws *WebSocketConnection) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return ws.fail(&CloseError{CloseProtocolError, "invalid close frame"})
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !validCloseCode(closeErr.Code) {
+			return ws.fail(&CloseError{CloseProtocolError, "invalid close code"})
+		}
+		if !utf8.ValidString(closeErr.Text) {
+			return ws.fail(&CloseError{CloseInvalidPayload, "invalid UTF-8"})
+		}
+	}
+
+	echo := []byte(nil)
+	if closeErr.Code != CloseNoStatusReceived {
+		echo = payload[:2]
 	}
-	
-	ws.closed = true
-	// Send close frame
-	closeFrame := []byte{0x88, 0x00} // FIN=1, opcode=8 (close)
-	_, err := ws.conn.Write(closeFrame)
+	ws.sendAndWait(CloseMessage, echo)
+	ws.closeConn()
+	return closeErr
+}
+
+// fail closes the connection after a read error, telling the peer why
+// when the error is a protocol violation
+func (ws *WebSocketConnection) fail(err error) error {
+	var closeErr *CloseError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &closeErr):
+		ws.sendAndWait(CloseMessage, closePayload(closeErr.Code, closeErr.Text))
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		err = &CloseError{CloseAbnormalClosure, "unexpected EOF"}
+	case errors.As(err, &netErr) && netErr.Timeout():
+		err = &CloseError{CloseAbnormalClosure, "peer stopped responding"}
+	case errors.Is(err, net.ErrClosed) && ws.closed.Load():
+		err = ErrWebSocketClosed
+	}
+	ws.closeConn()
 	return err
 }
 
+// closeConn closes the underlying connection once
+func (ws *WebSocketConnection) closeConn() {
+	ws.closeOnce.Do(func() {
+		ws.closed.Store(true)
+		close(ws.done)
+		ws.conn.Close()
+	})
+}
+
+// closePayload encodes a close code and a reason truncated to fit a
+// control frame
+func closePayload(code int, reason string) []byte {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return append(payload, reason...)
+}
+
+// validCloseCode reports whether a peer may send code in a close frame
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
 // WebSocket middleware for broadcasting
 func WebSocketBroadcast() *WebSocketBroadcaster {
 	return &WebSocketBroadcaster{
@@ -126,33 +740,93 @@ func WebSocketBroadcast() *WebSocketBroadcaster {
 	}
 }
 
+// WebSocketBroadcaster sends messages to a set of connections
 type WebSocketBroadcaster struct {
 	connections map[*WebSocketConnection]bool
 	mu          sync.RWMutex
+	backplane   *backplaneLink
 }
 
+// AddConnection registers a connection for broadcasts. It is removed
+// automatically once it closes.
 func (wb *WebSocketBroadcaster) AddConnection(conn *WebSocketConnection) {
 	wb.mu.Lock()
 	defer wb.mu.Unlock()
+	if wb.connections[conn] {
+		return
+	}
 	wb.connections[conn] = true
+
+	go func() {
+		<-conn.Done()
+		wb.RemoveConnection(conn)
+	}()
 }
 
+// RemoveConnection unregisters a connection
 func (wb *WebSocketBroadcaster) RemoveConnection(conn *WebSocketConnection) {
 	wb.mu.Lock()
 	defer wb.mu.Unlock()
 	delete(wb.connections, conn)
 }
 
+// Broadcast queues a text message on every open connection without
+// blocking. Clients whose send queue is full are disconnected. With a
+// backplane, connections on other instances receive it too.
 func (wb *WebSocketBroadcaster) Broadcast(message string) {
+	wb.broadcastLocal([]byte(message))
+
 	wb.mu.RLock()
-	defer wb.mu.RUnlock()
-	
+	link := wb.backplane
+	wb.mu.RUnlock()
+	if link != nil {
+		link.publish([]byte(message))
+	}
+}
+
+// UseBackplane relays broadcasts through a backplane channel shared by
+// every instance
+func (wb *WebSocketBroadcaster) UseBackplane(backplane Backplane, channel string) error {
+	link, err := newBackplaneLink(backplane, channel, wb.broadcastLocal)
+	if err != nil {
+		return err
+	}
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	if wb.backplane != nil {
+		wb.backplane.unsubscribe()
+	}
+	wb.backplane = link
+	return nil
+}
+
+// broadcastLocal delivers a message to the connections of this instance
+func (wb *WebSocketBroadcaster) broadcastLocal(data []byte) {
+	wb.mu.RLock()
+	connections := make([]*WebSocketConnection, 0, len(wb.connections))
 	for conn := range wb.connections {
-		if !conn.closed {
-			// Send in goroutine to avoid blocking
-			go func(c *WebSocketConnection) {
-				c.Send(message)
-			}(conn)
-		}
+		connections = append(connections, conn)
+	}
+	wb.mu.RUnlock()
+
+	for _, conn := range connections {
+		conn.deliver(TextMessage, data)
+	}
+}
+
+// deliver queues a fan-out message. A client that cannot keep up is
+// disconnected rather than slowing down everyone else.
+func (ws *WebSocketConnection) deliver(messageType int, data []byte) bool {
+	err := ws.SendAsync(messageType, data)
+	if errors.Is(err, ErrWebSocketQueueFull) {
+		ws.closeConn()
 	}
-}
\ No newline at end of file
+	return err == nil
+}
+
+// Count returns the number of registered connections
+func (wb *WebSocketBroadcaster) Count() int {
+	wb.mu.RLock()
+	defer wb.mu.RUnlock()
+	return len(wb.connections)
+}
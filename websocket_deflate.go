@@ -0,0 +1,182 @@
+package forge
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// permessage-deflate (RFC 7692)
+const (
+	deflateExtension  = "permessage-deflate"
+	deflateWindowSize = 1 << 15
+)
+
+// deflateTail completes a compressed message: the sync flush marker
+// stripped by the sender followed by an empty final block
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// flateWriterPools reuse compressors across connections without server
+// context takeover, indexed by level+2
+var flateWriterPools [12]sync.Pool
+
+// deflateParams are the negotiated permessage-deflate parameters
+type deflateParams struct {
+	serverNoContextTakeover bool
+	clientNoContextTakeover bool
+}
+
+// negotiateDeflate accepts the first permessage-deflate offer the server
+// can honor and returns the response extension header. Offers limiting the
+// server window below 32 KiB are declined because compress/flate always
+// uses the full window.
+func negotiateDeflate(r *http.Request, config *WebSocketConfig) (*deflateParams, string) {
+	for _, value := range r.Header.Values("Sec-WebSocket-Extensions") {
+		for _, offer := range strings.Split(value, ",") {
+			params, ok := parseDeflateOffer(offer)
+			if !ok {
+				continue
+			}
+			params.serverNoContextTakeover = params.serverNoContextTakeover || config.ServerNoContextTakeover
+			params.clientNoContextTakeover = params.clientNoContextTakeover || config.ClientNoContextTakeover
+
+			response := deflateExtension
+			if params.serverNoContextTakeover {
+				response += "; server_no_context_takeover"
+			}
+			if params.clientNoContextTakeover {
+				response += "; client_no_context_takeover"
+			}
+			return params, response
+		}
+	}
+	return nil, ""
+}
+
+// parseDeflateOffer parses one extension offer, rejecting other extensions
+// and offers with unknown, repeated or invalid parameters
+func parseDeflateOffer(offer string) (*deflateParams, bool) {
+	parts := strings.Split(offer, ";")
+	if strings.TrimSpace(parts[0]) != deflateExtension {
+		return nil, false
+	}
+
+	params := &deflateParams{}
+	seen := make(map[string]bool)
+	for _, part := range parts[1:] {
+		name, value, hasValue := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.TrimSpace(name)
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if seen[name] {
+			return nil, false
+		}
+		seen[name] = true
+
+		switch name {
+		case "server_no_context_takeover":
+			params.serverNoContextTakeover = true
+		case "client_no_context_takeover":
+			params.clientNoContextTakeover = true
+		case "server_max_window_bits":
+			if bits, err := strconv.Atoi(value); err != nil || bits != 15 {
+				return nil, false
+			}
+		case "client_max_window_bits":
+			// Our decompressor handles any window up to 32 KiB
+			if hasValue {
+				if bits, err := strconv.Atoi(value); err != nil || bits < 8 || bits > 15 {
+					return nil, false
+				}
+			}
+		default:
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// wsCompressor compresses outgoing messages; only the write pump uses it
+type wsCompressor struct {
+	level     int
+	takeover  bool
+	threshold int
+	buf       bytes.Buffer
+	writer    *flate.Writer // kept between messages with context takeover
+}
+
+// shouldCompress reports whether a data message is worth compressing
+func (c *wsCompressor) shouldCompress(opcode int, payload []byte) bool {
+	return (opcode == TextMessage || opcode == BinaryMessage) &&
+		len(payload) > 0 && len(payload) >= c.threshold
+}
+
+// compress deflates one message and strips the trailing sync marker. The
+// result is only valid until the next call.
+func (c *wsCompressor) compress(payload []byte) ([]byte, error) {
+	c.buf.Reset()
+
+	writer := c.writer
+	if writer == nil {
+		pool := &flateWriterPools[c.level+2]
+		if pooled, ok := pool.Get().(*flate.Writer); ok {
+			writer = pooled
+			writer.Reset(&c.buf)
+		} else {
+			var err error
+			if writer, err = flate.NewWriter(&c.buf, c.level); err != nil {
+				return nil, err
+			}
+		}
+		if c.takeover {
+			c.writer = writer
+		} else {
+			defer pool.Put(writer)
+		}
+	}
+
+	if _, err := writer.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(c.buf.Bytes(), deflateTail[:4]), nil
+}
+
+// wsDecompressor inflates incoming messages; only the reader uses it
+type wsDecompressor struct {
+	takeover bool
+	window   []byte // last 32 KiB of output, the dictionary for the next message
+	reader   io.ReadCloser
+}
+
+// decompress inflates one message, failing with 1009 once the output
+// exceeds limit so small frames cannot expand into huge messages
+func (d *wsDecompressor) decompress(data []byte, limit int64) ([]byte, error) {
+	input := io.MultiReader(bytes.NewReader(data), bytes.NewReader(deflateTail))
+	if d.reader == nil {
+		d.reader = flate.NewReaderDict(input, d.window)
+	} else if err := d.reader.(flate.Resetter).Reset(input, d.window); err != nil {
+		return nil, err
+	}
+
+	message, err := io.ReadAll(io.LimitReader(d.reader, limit+1))
+	if err != nil {
+		return nil, &CloseError{CloseInvalidPayload, "invalid compressed data"}
+	}
+	if int64(len(message)) > limit {
+		return nil, &CloseError{CloseMessageTooBig, "message too big"}
+	}
+
+	if d.takeover {
+		d.window = append(d.window, message...)
+		if len(d.window) > deflateWindowSize {
+			d.window = append(d.window[:0], d.window[len(d.window)-deflateWindowSize:]...)
+		}
+	}
+	return message, nil
+}
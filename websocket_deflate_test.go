@@ -0,0 +1,187 @@
+package forge
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// deflateEchoServer echoes messages over connections negotiating compression
+func deflateEchoServer(t *testing.T, configure func(*WebSocketConfig)) (*httptest.Server, chan error) {
+	done := make(chan error, 1)
+	config := NewWebSocketConfig()
+	config.EnableCompression = true
+	config.CompressionThreshold = 64
+	if configure != nil {
+		configure(config)
+	}
+	app := New()
+	app.WebSocketWithConfig("/ws", config, func(conn *WebSocketConnection) {
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				done <- err
+				return
+			}
+			conn.WriteMessage(messageType, data)
+		}
+	})
+	server := httptest.NewServer(app)
+	t.Cleanup(server.Close)
+	return server, done
+}
+
+// clientDeflate compresses like a browser, keeping context between messages
+type clientDeflate struct {
+	buf    bytes.Buffer
+	writer *flate.Writer
+}
+
+func (c *clientDeflate) compress(data []byte) []byte {
+	c.buf.Reset()
+	if c.writer == nil {
+		c.writer, _ = flate.NewWriter(&c.buf, flate.BestCompression)
+	}
+	c.writer.Write(data)
+	c.writer.Flush()
+	return append([]byte(nil), bytes.TrimSuffix(c.buf.Bytes(), deflateTail[:4])...)
+}
+
+func inflate(t *testing.T, dict, data []byte) []byte {
+	t.Helper()
+	reader := flate.NewReaderDict(io.MultiReader(bytes.NewReader(data), bytes.NewReader(deflateTail)), dict)
+	out, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestWebSocketDeflateNegotiation(t *testing.T) {
+	server, _ := deflateEchoServer(t, func(config *WebSocketConfig) {
+		config.ClientNoContextTakeover = true
+	})
+
+	tests := map[string]string{
+		"permessage-deflate; client_max_window_bits":                        "permessage-deflate; client_no_context_takeover",
+		"permessage-deflate; server_no_context_takeover":                    "permessage-deflate; server_no_context_takeover; client_no_context_takeover",
+		"permessage-deflate; server_max_window_bits=10, permessage-deflate": "permessage-deflate; client_no_context_takeover",
+		"permessage-deflate; server_max_window_bits=10":                     "",
+		"permessage-deflate; unknown":                                       "",
+		"x-webkit-deflate-frame":                                            "",
+	}
+	for offer, expected := range tests {
+		client := dialWebSocket(t, server, "/ws", http.Header{"Sec-Websocket-Extensions": {offer}})
+		if got := client.resp.Header.Get("Sec-WebSocket-Extensions"); got != expected {
+			t.Errorf("offer %q: expected %q, got %q", offer, expected, got)
+		}
+	}
+}
+
+func TestWebSocketDeflateContextTakeover(t *testing.T) {
+	server, _ := deflateEchoServer(t, nil)
+	client := dialWebSocket(t, server, "/ws", http.Header{"Sec-Websocket-Extensions": {"permessage-deflate"}})
+
+	snapshot := []byte(`{"items":[` + strings.Repeat(`{"id":1,"name":"widget","price":9.99},`, 100) + `{}]}`)
+	compressor := &clientDeflate{}
+	var window []byte
+	sizes := make([]int, 0, 2)
+	for i := 0; i < 2; i++ {
+		// The second message references the first through the shared window
+		client.writeFrame(0xC0|TextMessage, compressor.compress(snapshot))
+
+		b0, payload := client.readFrame()
+		if b0 != 0xC0|TextMessage {
+			t.Fatalf("expected compressed text frame, got %#x", b0)
+		}
+		message := inflate(t, window, payload)
+		if !bytes.Equal(message, snapshot) {
+			t.Fatalf("message %d did not round-trip", i)
+		}
+		window = append(window, message...)
+		sizes = append(sizes, len(payload))
+	}
+	if sizes[1] >= sizes[0] {
+		t.Errorf("expected context takeover to shrink the second message, got %v", sizes)
+	}
+
+	// Messages under the threshold are sent as is
+	client.writeFrame(0x80|TextMessage, []byte("small"))
+	if b0, payload := client.readFrame(); b0 != 0x80|TextMessage || string(payload) != "small" {
+		t.Errorf("expected uncompressed small message, got %#x %q", b0, payload)
+	}
+}
+
+func TestWebSocketDeflateNoContextTakeover(t *testing.T) {
+	server, _ := deflateEchoServer(t, nil)
+	client := dialWebSocket(t, server, "/ws", http.Header{
+		"Sec-Websocket-Extensions": {"permessage-deflate; server_no_context_takeover"},
+	})
+
+	message := bytes.Repeat([]byte("forge "), 200)
+	for i := 0; i < 2; i++ {
+		client.writeFrame(0x80|BinaryMessage, message)
+		b0, payload := client.readFrame()
+		if b0 != 0xC0|BinaryMessage || !bytes.Equal(inflate(t, nil, payload), message) {
+			t.Fatalf("message %d must decompress without a window", i)
+		}
+	}
+}
+
+func TestWebSocketDeflateServerNoContextTakeoverConfig(t *testing.T) {
+	server, _ := deflateEchoServer(t, func(config *WebSocketConfig) {
+		config.ServerNoContextTakeover = true
+	})
+	client := dialWebSocket(t, server, "/ws", http.Header{"Sec-Websocket-Extensions": {"permessage-deflate"}})
+	if got := client.resp.Header.Get("Sec-WebSocket-Extensions"); got != "permessage-deflate; server_no_context_takeover" {
+		t.Fatalf("expected server_no_context_takeover in response, got %q", got)
+	}
+
+	// Every message is compressed on its own, so repeats stay the same size
+	message := bytes.Repeat([]byte("forge "), 200)
+	var sizes []int
+	for i := 0; i < 2; i++ {
+		client.writeFrame(0x80|TextMessage, message)
+		b0, payload := client.readFrame()
+		if b0 != 0xC0|TextMessage || !bytes.Equal(inflate(t, nil, payload), message) {
+			t.Fatalf("message %d must decompress without a window", i)
+		}
+		sizes = append(sizes, len(payload))
+	}
+	if sizes[0] != sizes[1] {
+		t.Errorf("expected independent messages of equal size, got %v", sizes)
+	}
+}
+
+func TestWebSocketDeflateErrors(t *testing.T) {
+	// Compressed frames are a protocol error without negotiation
+	server, done := echoServer(t)
+	client := dialWebSocket(t, server, "/ws", nil)
+	client.writeFrame(0xC0|TextMessage, (&clientDeflate{}).compress([]byte("hello")))
+	client.expectClose(CloseProtocolError)
+	<-done
+
+	// Decompressed size is bounded by the read limit
+	server, done = deflateEchoServer(t, func(config *WebSocketConfig) {
+		config.ReadLimit = 4096
+	})
+	client = dialWebSocket(t, server, "/ws", http.Header{"Sec-Websocket-Extensions": {"permessage-deflate"}})
+	client.writeFrame(0xC0|BinaryMessage, (&clientDeflate{}).compress(make([]byte, 1<<20)))
+	client.expectClose(CloseMessageTooBig)
+	var closeErr *CloseError
+	if err := <-done; !errors.As(err, &closeErr) || closeErr.Code != CloseMessageTooBig {
+		t.Errorf("expected message too big, got %v", err)
+	}
+
+	// Continuation frames never carry RSV1
+	server, _ = deflateEchoServer(t, nil)
+	client = dialWebSocket(t, server, "/ws", http.Header{"Sec-Websocket-Extensions": {"permessage-deflate"}})
+	client.writeFrame(TextMessage, []byte("a"))
+	client.writeFrame(0xC0|continuationFrame, []byte("b"))
+	client.expectClose(CloseProtocolError)
+}
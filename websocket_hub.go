@@ -0,0 +1,350 @@
+package forge
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// Presence event types
+const (
+	PresenceJoin  = "join"
+	PresenceLeave = "leave"
+)
+
+// PresenceEvent reports a user entering or leaving a room. Authenticated
+// users join with their first connection and leave with their last one;
+// anonymous connections are reported individually.
+type PresenceEvent struct {
+	Type   string
+	Room   string
+	UserID string
+	Conn   *WebSocketConnection // The connection that joined or left
+}
+
+// WebSocketHub groups connections into named rooms and addresses them by
+// room, by user ID (from GetUserID at upgrade time) or all at once. Sends
+// never block: clients whose send queue is full are disconnected.
+type WebSocketHub struct {
+	mu          sync.RWMutex
+	connections map[*WebSocketConnection]map[string]bool // rooms joined by each connection
+	rooms       map[string]map[*WebSocketConnection]bool
+	users       map[string]map[*WebSocketConnection]bool
+	presence    map[string]map[string]int // room -> user ID -> connections in the room
+	onPresence  []func(PresenceEvent)
+	backplane   *backplaneLink
+}
+
+// hubMessage is a hub send relayed through a backplane
+type hubMessage struct {
+	Target string   `json:"target"` // "all", "room" or "user"
+	Key    string   `json:"key,omitempty"`
+	Type   int      `json:"type"`
+	Data   []byte   `json:"data"`
+	Except []string `json:"except,omitempty"` // Connection IDs
+}
+
+// NewWebSocketHub creates an empty hub
+func NewWebSocketHub() *WebSocketHub {
+	return &WebSocketHub{
+		connections: make(map[*WebSocketConnection]map[string]bool),
+		rooms:       make(map[string]map[*WebSocketConnection]bool),
+		users:       make(map[string]map[*WebSocketConnection]bool),
+		presence:    make(map[string]map[string]int),
+	}
+}
+
+// OnPresence registers a callback for join and leave events. Callbacks run
+// outside the hub lock, so they may send through the hub.
+func (h *WebSocketHub) OnPresence(callback func(PresenceEvent)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onPresence = append(h.onPresence, callback)
+}
+
+// Register adds a connection to the hub. It is unregistered, leaving all
+// its rooms, once it closes.
+func (h *WebSocketHub) Register(conn *WebSocketConnection) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.registerLocked(conn)
+}
+
+// registerLocked registers conn if needed; the caller holds h.mu
+func (h *WebSocketHub) registerLocked(conn *WebSocketConnection) {
+	if _, ok := h.connections[conn]; ok {
+		return
+	}
+	h.connections[conn] = make(map[string]bool)
+	if conn.userID != "" {
+		addToSet(h.users, conn.userID, conn)
+	}
+
+	go func() {
+		<-conn.Done()
+		h.Unregister(conn)
+	}()
+}
+
+// Unregister removes a connection from the hub and all its rooms
+func (h *WebSocketHub) Unregister(conn *WebSocketConnection) {
+	h.mu.Lock()
+	rooms, ok := h.connections[conn]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+	events := make([]PresenceEvent, 0, len(rooms))
+	for room := range rooms {
+		if event, left := h.leaveLocked(conn, room); left {
+			events = append(events, event)
+		}
+	}
+	delete(h.connections, conn)
+	if conn.userID != "" {
+		removeFromSet(h.users, conn.userID, conn)
+	}
+	callbacks := h.onPresence
+	h.mu.Unlock()
+
+	notifyPresence(callbacks, events)
+}
+
+// Join adds a connection to a room, registering it if needed
+func (h *WebSocketHub) Join(conn *WebSocketConnection, room string) {
+	h.mu.Lock()
+	h.registerLocked(conn)
+	if h.connections[conn][room] {
+		h.mu.Unlock()
+		return
+	}
+	h.connections[conn][room] = true
+	addToSet(h.rooms, room, conn)
+
+	first := true
+	if conn.userID != "" {
+		if h.presence[room] == nil {
+			h.presence[room] = make(map[string]int)
+		}
+		h.presence[room][conn.userID]++
+		first = h.presence[room][conn.userID] == 1
+	}
+	callbacks := h.onPresence
+	h.mu.Unlock()
+
+	if first {
+		notifyPresence(callbacks, []PresenceEvent{{Type: PresenceJoin, Room: room, UserID: conn.userID, Conn: conn}})
+	}
+}
+
+// Leave removes a connection from a room
+func (h *WebSocketHub) Leave(conn *WebSocketConnection, room string) {
+	h.mu.Lock()
+	event, left := h.leaveLocked(conn, room)
+	callbacks := h.onPresence
+	h.mu.Unlock()
+
+	if left {
+		notifyPresence(callbacks, []PresenceEvent{event})
+	}
+}
+
+// leaveLocked removes conn from room and reports whether its user left;
+// the caller holds h.mu
+func (h *WebSocketHub) leaveLocked(conn *WebSocketConnection, room string) (PresenceEvent, bool) {
+	if !h.connections[conn][room] {
+		return PresenceEvent{}, false
+	}
+	delete(h.connections[conn], room)
+	removeFromSet(h.rooms, room, conn)
+
+	last := true
+	if conn.userID != "" {
+		h.presence[room][conn.userID]--
+		last = h.presence[room][conn.userID] == 0
+		if last {
+			delete(h.presence[room], conn.userID)
+		}
+		if len(h.presence[room]) == 0 {
+			delete(h.presence, room)
+		}
+	}
+	return PresenceEvent{Type: PresenceLeave, Room: room, UserID: conn.userID, Conn: conn}, last
+}
+
+// Broadcast sends a message to every connection except the given ones and
+// returns how many local connections it was queued on
+func (h *WebSocketHub) Broadcast(messageType int, data []byte, except ...*WebSocketConnection) int {
+	return h.send(hubMessage{Target: "all", Type: messageType, Data: data, Except: connectionIDs(except)})
+}
+
+// SendToRoom sends a message to the members of a room except the given
+// connections, typically the sender
+func (h *WebSocketHub) SendToRoom(room string, messageType int, data []byte, except ...*WebSocketConnection) int {
+	return h.send(hubMessage{Target: "room", Key: room, Type: messageType, Data: data, Except: connectionIDs(except)})
+}
+
+// SendToUser sends a message to every connection of a user
+func (h *WebSocketHub) SendToUser(userID string, messageType int, data []byte) int {
+	return h.send(hubMessage{Target: "user", Key: userID, Type: messageType, Data: data})
+}
+
+// UseBackplane relays sends through a backplane channel, so rooms and
+// users span every instance. Counts returned by the send methods only
+// cover local connections; presence stays local too.
+func (h *WebSocketHub) UseBackplane(backplane Backplane, channel string) error {
+	link, err := newBackplaneLink(backplane, channel, func(payload []byte) {
+		var msg hubMessage
+		if json.Unmarshal(payload, &msg) == nil {
+			h.deliverLocal(msg)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.backplane != nil {
+		h.backplane.unsubscribe()
+	}
+	h.backplane = link
+	return nil
+}
+
+// send delivers a message locally and publishes it to other instances
+func (h *WebSocketHub) send(msg hubMessage) int {
+	sent := h.deliverLocal(msg)
+
+	h.mu.RLock()
+	link := h.backplane
+	h.mu.RUnlock()
+	if link != nil {
+		if payload, err := json.Marshal(msg); err == nil {
+			link.publish(payload)
+		}
+	}
+	return sent
+}
+
+// deliverLocal queues a message on the matching connections of this instance
+func (h *WebSocketHub) deliverLocal(msg hubMessage) int {
+	h.mu.RLock()
+	var targets []*WebSocketConnection
+	switch msg.Target {
+	case "all":
+		targets = make([]*WebSocketConnection, 0, len(h.connections))
+		for conn := range h.connections {
+			targets = append(targets, conn)
+		}
+	case "room":
+		targets = setMembers(h.rooms[msg.Key])
+	case "user":
+		targets = setMembers(h.users[msg.Key])
+	}
+	h.mu.RUnlock()
+
+	sent := 0
+	for _, conn := range targets {
+		if containsString(msg.Except, conn.id) {
+			continue
+		}
+		if conn.deliver(msg.Type, msg.Data) {
+			sent++
+		}
+	}
+	return sent
+}
+
+// Members returns the sorted IDs of the users present in a room
+func (h *WebSocketHub) Members(room string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	members := make([]string, 0, len(h.presence[room]))
+	for userID := range h.presence[room] {
+		members = append(members, userID)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// RoomSize returns the number of connections in a room, anonymous ones included
+func (h *WebSocketHub) RoomSize(room string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.rooms[room])
+}
+
+// Rooms returns the sorted rooms a connection has joined
+func (h *WebSocketHub) Rooms(conn *WebSocketConnection) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	rooms := make([]string, 0, len(h.connections[conn]))
+	for room := range h.connections[conn] {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+// IsOnline reports whether a user has at least one registered connection
+func (h *WebSocketHub) IsOnline(userID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.users[userID]) > 0
+}
+
+// Count returns the number of registered connections
+func (h *WebSocketHub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.connections)
+}
+
+func connectionIDs(conns []*WebSocketConnection) []string {
+	ids := make([]string, 0, len(conns))
+	for _, conn := range conns {
+		if conn != nil {
+			ids = append(ids, conn.id)
+		}
+	}
+	return ids
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func notifyPresence(callbacks []func(PresenceEvent), events []PresenceEvent) {
+	for _, event := range events {
+		for _, callback := range callbacks {
+			callback(event)
+		}
+	}
+}
+
+func addToSet(sets map[string]map[*WebSocketConnection]bool, key string, conn *WebSocketConnection) {
+	if sets[key] == nil {
+		sets[key] = make(map[*WebSocketConnection]bool)
+	}
+	sets[key][conn] = true
+}
+
+func removeFromSet(sets map[string]map[*WebSocketConnection]bool, key string, conn *WebSocketConnection) {
+	delete(sets[key], conn)
+	if len(sets[key]) == 0 {
+		delete(sets, key)
+	}
+}
+
+func setMembers(set map[*WebSocketConnection]bool) []*WebSocketConnection {
+	members := make([]*WebSocketConnection, 0, len(set))
+	for conn := range set {
+		members = append(members, conn)
+	}
+	return members
+}
@@ -0,0 +1,112 @@
+package forge
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWebSocketHubRoomsAndPresence(t *testing.T) {
+	hub := NewWebSocketHub()
+	events := make(chan PresenceEvent, 10)
+	hub.OnPresence(func(event PresenceEvent) { events <- event })
+	joined := make(chan struct{}, 10)
+
+	app := New()
+	app.Use(func(c *Context) error {
+		if user := c.Request.URL.Query().Get("user"); user != "" {
+			c.Set("user_id", user)
+		}
+		return c.Next()
+	})
+	app.WebSocket("/ws", func(conn *WebSocketConnection) {
+		room := conn.Request().URL.Query().Get("room")
+		hub.Join(conn, room)
+		joined <- struct{}{}
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			hub.SendToRoom(room, TextMessage, data, conn)
+		}
+	})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	expectEvent := func(eventType, room, userID string) {
+		t.Helper()
+		select {
+		case event := <-events:
+			if event.Type != eventType || event.Room != room || event.UserID != userID {
+				t.Fatalf("expected %s %s/%s, got %+v", eventType, room, userID, event)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected %s event for %s", eventType, userID)
+		}
+	}
+
+	aliceTab1 := dialWebSocket(t, server, "/ws?user=alice&room=general", nil)
+	<-joined
+	expectEvent(PresenceJoin, "general", "alice")
+	aliceTab2 := dialWebSocket(t, server, "/ws?user=alice&room=general", nil)
+	<-joined
+	bob := dialWebSocket(t, server, "/ws?user=bob&room=general", nil)
+	<-joined
+	expectEvent(PresenceJoin, "general", "bob")
+	carol := dialWebSocket(t, server, "/ws?user=carol&room=random", nil)
+	<-joined
+	expectEvent(PresenceJoin, "random", "carol")
+
+	if members := hub.Members("general"); !reflect.DeepEqual(members, []string{"alice", "bob"}) {
+		t.Errorf("unexpected members %v", members)
+	}
+	if hub.RoomSize("general") != 3 || !hub.IsOnline("carol") || hub.IsOnline("dave") {
+		t.Error("unexpected room size or online state")
+	}
+
+	// Room messages skip the sender and other rooms
+	bob.writeFrame(0x80|TextMessage, []byte("hi all"))
+	for _, client := range []*wsTestClient{aliceTab1, aliceTab2} {
+		if _, payload := client.readFrame(); string(payload) != "hi all" {
+			t.Errorf("expected room message, got %q", payload)
+		}
+	}
+	if sent := hub.SendToUser("bob", TextMessage, []byte("direct")); sent != 1 {
+		t.Errorf("expected one connection for bob, got %d", sent)
+	}
+	if _, payload := bob.readFrame(); string(payload) != "direct" {
+		t.Errorf("sender should not get its own room message, got %q", payload)
+	}
+	if sent := hub.Broadcast(TextMessage, []byte("everyone"), carol.serverConn(hub)); sent != 3 {
+		t.Errorf("expected broadcast to skip carol, reached %d", sent)
+	}
+
+	// A user leaves with their last connection
+	aliceTab1.conn.Close()
+	for hub.RoomSize("general") != 2 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	aliceTab2.conn.Close()
+	expectEvent(PresenceLeave, "general", "alice")
+	if members := hub.Members("general"); !reflect.DeepEqual(members, []string{"bob"}) {
+		t.Errorf("unexpected members after leave %v", members)
+	}
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event %+v", event)
+	default:
+	}
+}
+
+// serverConn finds the hub connection of the only user in a test client's room
+func (c *wsTestClient) serverConn(hub *WebSocketHub) *WebSocketConnection {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	room := c.resp.Request.URL.Query().Get("room")
+	for conn := range hub.rooms[room] {
+		return conn
+	}
+	return nil
+}
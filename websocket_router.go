@@ -0,0 +1,279 @@
+package forge
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is the JSON envelope exchanged by a MessageRouter. Requests carry
+// an optional ID that replies and errors echo back for correlation.
+type Message struct {
+	Type  string          `json:"type"`
+	ID    json.RawMessage `json:"id,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error *MessageError   `json:"error,omitempty"`
+}
+
+// MessageError is the error sent back for a failed message
+type MessageError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Message errors
+var (
+	ErrMessageInvalid      = NewMessageError("invalid_message", "message must be a JSON object with a type")
+	ErrMessageUnknownType  = NewMessageError("unknown_type", "no handler for message type")
+	ErrMessageInvalidData  = NewMessageError("invalid_data", "message data is invalid")
+	ErrMessageUnauthorized = NewMessageError("unauthorized", "authentication required")
+)
+
+// NewMessageError creates a message error
+func NewMessageError(code, message string) *MessageError {
+	return &MessageError{Code: code, Message: message}
+}
+
+// Error returns the error message
+func (e *MessageError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// MessageHandlerFunc handles a routed message
+type MessageHandlerFunc func(*MessageContext) error
+
+// MessageMiddlewareFunc wraps message handlers; call c.Next() to continue
+type MessageMiddlewareFunc func(*MessageContext) error
+
+// MessageDataValidator is implemented by message payloads that validate
+// themselves after MessageContext.Bind decodes them
+type MessageDataValidator interface {
+	Validate(v *Validator) error
+}
+
+// MessageRouter dispatches JSON envelopes received on WebSocket connections
+// to handlers registered by type, with a middleware chain like HTTP routes.
+// Messages of one connection are handled in order.
+type MessageRouter struct {
+	mu         sync.RWMutex
+	handlers   map[string][]MessageMiddlewareFunc // middleware followed by the handler
+	middleware []MessageMiddlewareFunc
+}
+
+// NewMessageRouter creates an empty message router
+func NewMessageRouter() *MessageRouter {
+	return &MessageRouter{handlers: make(map[string][]MessageMiddlewareFunc)}
+}
+
+// Use adds middleware running before every handler
+func (r *MessageRouter) Use(middleware MessageMiddlewareFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(r.middleware, middleware)
+}
+
+// Handle registers a handler for a message type. The given middleware runs
+// after the router middleware.
+func (r *MessageRouter) Handle(messageType string, handler MessageHandlerFunc, middleware ...MessageMiddlewareFunc) {
+	chain := append(append([]MessageMiddlewareFunc(nil), middleware...), MessageMiddlewareFunc(handler))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[messageType] = chain
+}
+
+// Handler returns a WebSocketHandler serving the router
+func (r *MessageRouter) Handler() WebSocketHandler {
+	return func(conn *WebSocketConnection) {
+		r.Serve(conn)
+	}
+}
+
+// Serve reads and dispatches messages until the connection closes.
+// Binary messages are rejected with ErrMessageInvalid.
+func (r *MessageRouter) Serve(conn *WebSocketConnection) error {
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var msg Message
+		if messageType != TextMessage || json.Unmarshal(data, &msg) != nil || msg.Type == "" {
+			c := &MessageContext{Conn: conn, ID: msg.ID}
+			c.sendError(ErrMessageInvalid)
+			continue
+		}
+		r.dispatch(conn, &msg)
+	}
+}
+
+// dispatch runs the middleware chain for one message
+func (r *MessageRouter) dispatch(conn *WebSocketConnection, msg *Message) {
+	c := &MessageContext{Conn: conn, Type: msg.Type, ID: msg.ID, Data: msg.Data}
+
+	r.mu.RLock()
+	handler, ok := r.handlers[msg.Type]
+	c.chain = append(append([]MessageMiddlewareFunc(nil), r.middleware...), handler...)
+	r.mu.RUnlock()
+	if !ok {
+		// Router middleware (logging, auth) still sees unknown types
+		c.chain = append(c.chain, func(*MessageContext) error { return ErrMessageUnknownType })
+	}
+
+	c.index = -1
+	if err := c.Next(); err != nil {
+		c.sendError(err)
+	}
+}
+
+// MessageContext carries one routed message through the middleware chain
+type MessageContext struct {
+	Conn *WebSocketConnection
+	Type string
+	ID   json.RawMessage
+	Data json.RawMessage
+
+	locals map[string]interface{}
+	chain  []MessageMiddlewareFunc
+	index  int
+}
+
+// Next runs the next middleware or the handler
+func (c *MessageContext) Next() error {
+	c.index++
+	if c.index < len(c.chain) {
+		return c.chain[c.index](c)
+	}
+	return nil
+}
+
+// Set stores a value for later middleware and the handler
+func (c *MessageContext) Set(key string, value interface{}) {
+	if c.locals == nil {
+		c.locals = make(map[string]interface{})
+	}
+	c.locals[key] = value
+}
+
+// Get returns a value stored with Set
+func (c *MessageContext) Get(key string) interface{} {
+	return c.locals[key]
+}
+
+// UserID returns the user ID the connection authenticated with
+func (c *MessageContext) UserID() string {
+	return c.Conn.UserID()
+}
+
+// GetValidator returns the validator stored under "validator", or a new one
+func (c *MessageContext) GetValidator() *Validator {
+	if v, ok := c.Get("validator").(*Validator); ok {
+		return v
+	}
+	return NewValidator()
+}
+
+// Bind decodes the message data into v and validates it when v implements
+// MessageDataValidator. Failures are reported as invalid_data errors.
+func (c *MessageContext) Bind(v interface{}) error {
+	if len(c.Data) == 0 || json.Unmarshal(c.Data, v) != nil {
+		return ErrMessageInvalidData
+	}
+	if validatable, ok := v.(MessageDataValidator); ok {
+		if err := validatable.Validate(c.GetValidator()); err != nil {
+			return NewMessageError(ErrMessageInvalidData.Code, err.Error())
+		}
+	}
+	return nil
+}
+
+// Reply answers the message with data, echoing its type and ID
+func (c *MessageContext) Reply(data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return c.write(&Message{Type: c.Type, ID: c.ID, Data: encoded})
+}
+
+// Send pushes a message of any type to the connection, uncorrelated
+func (c *MessageContext) Send(messageType string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return c.write(&Message{Type: messageType, Data: encoded})
+}
+
+// sendError answers the message with an error. HTTPErrors, such as those
+// returned by authorization helpers, keep their status as the code; other
+// errors are logged and reported as internal errors.
+func (c *MessageContext) sendError(err error) {
+	var msgErr *MessageError
+	var httpErr *HTTPError
+	switch {
+	case errors.As(err, &msgErr):
+	case errors.As(err, &httpErr):
+		code := strings.ToLower(strings.ReplaceAll(http.StatusText(httpErr.Code), " ", "_"))
+		msgErr = NewMessageError(code, httpErr.Message)
+	default:
+		log.Printf("websocket message %s: %v", c.Type, err)
+		msgErr = NewMessageError("internal_error", "internal error")
+	}
+
+	messageType := c.Type
+	if messageType == "" {
+		messageType = "error"
+	}
+	c.write(&Message{Type: messageType, ID: c.ID, Error: msgErr})
+}
+
+func (c *MessageContext) write(msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.Conn.WriteMessage(TextMessage, data)
+}
+
+// MessageLogger logs every routed message with its duration and outcome
+func MessageLogger() MessageMiddlewareFunc {
+	return func(c *MessageContext) error {
+		start := time.Now()
+		err := c.Next()
+		outcome := "ok"
+		if err != nil {
+			outcome = err.Error()
+		}
+		log.Printf("[ws] %s %s - %v - %s", c.Conn.RealIP(), c.Type, time.Since(start), outcome)
+		return err
+	}
+}
+
+// MessageRecovery turns handler panics into internal errors instead of
+// dropping the connection
+func MessageRecovery() MessageMiddlewareFunc {
+	return func(c *MessageContext) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Panic recovered in message %s: %v", c.Type, r)
+				err = NewMessageError("internal_error", "internal error")
+			}
+		}()
+		return c.Next()
+	}
+}
+
+// MessageRequireAuth rejects messages on connections without a user ID
+func MessageRequireAuth() MessageMiddlewareFunc {
+	return func(c *MessageContext) error {
+		if c.UserID() == "" {
+			return ErrMessageUnauthorized
+		}
+		return c.Next()
+	}
+}
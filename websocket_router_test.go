@@ -0,0 +1,178 @@
+package forge
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type chatSend struct {
+	Room string `json:"room"`
+	Text string `json:"text"`
+}
+
+func (m *chatSend) Validate(v *Validator) error {
+	if err := v.ValidateRequired(m.Room, "room"); err != nil {
+		return err
+	}
+	return v.ValidateLength(m.Text, 1, 20, "text")
+}
+
+func routerServer(t *testing.T) *httptest.Server {
+	router := NewMessageRouter()
+	router.Use(MessageRecovery())
+	router.Handle("ping", func(c *MessageContext) error {
+		return c.Reply("pong")
+	})
+	router.Handle("chat.send", func(c *MessageContext) error {
+		var msg chatSend
+		if err := c.Bind(&msg); err != nil {
+			return err
+		}
+		c.Send("chat.message", map[string]string{"from": c.UserID(), "text": msg.Text})
+		return c.Reply(map[string]string{"status": "sent"})
+	}, MessageRequireAuth())
+	router.Handle("admin", func(c *MessageContext) error {
+		return ErrForbidden
+	})
+	router.Handle("fail", func(c *MessageContext) error {
+		return errors.New("database down")
+	})
+	router.Handle("panic", func(c *MessageContext) error {
+		panic("boom")
+	})
+
+	app := New()
+	app.Use(func(c *Context) error {
+		if user := c.Request.URL.Query().Get("user"); user != "" {
+			c.Set("user_id", user)
+		}
+		return c.Next()
+	})
+	app.WebSocket("/ws", router.Handler())
+	server := httptest.NewServer(app)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (c *wsTestClient) call(request string) Message {
+	c.t.Helper()
+	c.writeFrame(0x80|TextMessage, []byte(request))
+	return c.readMessage()
+}
+
+func (c *wsTestClient) readMessage() Message {
+	c.t.Helper()
+	b0, payload := c.readFrame()
+	if b0 != 0x80|TextMessage {
+		c.t.Fatalf("expected text frame, got %#x", b0)
+	}
+	var msg Message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		c.t.Fatalf("invalid reply %q: %v", payload, err)
+	}
+	return msg
+}
+
+func TestMessageRouterReplies(t *testing.T) {
+	server := routerServer(t)
+	client := dialWebSocket(t, server, "/ws?user=alice", nil)
+
+	reply := client.call(`{"type":"ping","id":7}`)
+	if reply.Type != "ping" || string(reply.ID) != "7" || string(reply.Data) != `"pong"` || reply.Error != nil {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+
+	push := client.call(`{"type":"chat.send","id":"a1","data":{"room":"general","text":"hi"}}`)
+	if push.Type != "chat.message" || push.ID != nil || string(push.Data) != `{"from":"alice","text":"hi"}` {
+		t.Fatalf("unexpected push %+v", push)
+	}
+	reply = client.readMessage()
+	if reply.Type != "chat.send" || string(reply.ID) != `"a1"` || string(reply.Data) != `{"status":"sent"}` {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+}
+
+func TestMessageRouterErrors(t *testing.T) {
+	server := routerServer(t)
+	client := dialWebSocket(t, server, "/ws?user=alice", nil)
+
+	tests := []struct {
+		request string
+		code    string
+	}{
+		{`not json`, "invalid_message"},
+		{`{"id":1}`, "invalid_message"},
+		{`{"type":"missing","id":1}`, "unknown_type"},
+		{`{"type":"chat.send","id":1,"data":"text"}`, "invalid_data"},
+		{`{"type":"chat.send","id":1,"data":{"room":"general","text":""}}`, "invalid_data"},
+		{`{"type":"admin","id":1}`, "forbidden"},
+		{`{"type":"fail","id":1}`, "internal_error"},
+		{`{"type":"panic","id":1}`, "internal_error"},
+	}
+	for _, tt := range tests {
+		reply := client.call(tt.request)
+		if reply.Error == nil || reply.Error.Code != tt.code {
+			t.Errorf("%s: expected %s error, got %+v", tt.request, tt.code, reply)
+		}
+		if tt.request != "not json" && string(reply.ID) != "1" {
+			t.Errorf("%s: expected id 1 to be echoed, got %s", tt.request, reply.ID)
+		}
+	}
+
+	// The connection survives errors and panics
+	if reply := client.call(`{"type":"ping"}`); reply.Error != nil {
+		t.Fatalf("unexpected error after failures: %+v", reply.Error)
+	}
+}
+
+func TestMessageRouterRequireAuth(t *testing.T) {
+	server := routerServer(t)
+	client := dialWebSocket(t, server, "/ws", nil)
+
+	reply := client.call(`{"type":"chat.send","id":1,"data":{"room":"general","text":"hi"}}`)
+	if reply.Error == nil || reply.Error.Code != "unauthorized" {
+		t.Fatalf("expected unauthorized, got %+v", reply)
+	}
+}
+
+// logLines forwards each log write to a channel
+type logLines chan string
+
+func (l logLines) Write(p []byte) (int, error) {
+	l <- string(p)
+	return len(p), nil
+}
+
+func TestMessageLoggerUsesRealIP(t *testing.T) {
+	lines := make(logLines, 10)
+	log.SetOutput(lines)
+	defer log.SetOutput(os.Stderr)
+
+	router := NewMessageRouter()
+	router.Use(MessageLogger())
+	router.Handle("ping", func(c *MessageContext) error { return c.Reply("pong") })
+
+	app := New()
+	app.SetTrustedProxies("127.0.0.1")
+	app.WebSocket("/ws", router.Handler())
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	client := dialWebSocket(t, server, "/ws", http.Header{"X-Forwarded-For": {"203.0.113.7"}})
+	client.call(`{"type":"ping","id":1}`)
+	select {
+	case line := <-lines:
+		if !strings.Contains(line, "[ws] 203.0.113.7 ping") {
+			t.Errorf("expected forwarded client IP in log, got %q", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the message to be logged")
+	}
+}
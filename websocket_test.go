@@ -0,0 +1,594 @@
+package forge
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsTestClient is a minimal RFC 6455 client speaking raw frames
+type wsTestClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+	resp *http.Response
+}
+
+// dialWebSocket performs the opening handshake against an httptest server
+func dialWebSocket(t *testing.T, server *httptest.Server, path string, header http.Header) *wsTestClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest("GET", server.URL+path, nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &wsTestClient{t: t, conn: conn, r: r, resp: resp}
+}
+
+// writeFrame sends a masked frame with the given first header byte
+func (c *wsTestClient) writeFrame(b0 byte, payload []byte) {
+	c.writeRawFrame(b0, payload, true)
+}
+
+func (c *wsTestClient) writeRawFrame(b0 byte, payload []byte, masked bool) {
+	c.t.Helper()
+	frame := []byte{b0}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) < 65536:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	if masked {
+		mask := []byte{0x12, 0x34, 0x56, 0x78}
+		frame = append(frame, mask...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// readFrame reads one server frame and returns its first header byte
+func (c *wsTestClient) readFrame() (byte, []byte) {
+	c.t.Helper()
+	var header [8]byte
+	if _, err := io.ReadFull(c.r, header[:2]); err != nil {
+		c.t.Fatal(err)
+	}
+	b0 := header[0]
+	if header[1]&0x80 != 0 {
+		c.t.Fatal("server frames must not be masked")
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		io.ReadFull(c.r, header[:2])
+		length = uint64(binary.BigEndian.Uint16(header[:2]))
+	case 127:
+		io.ReadFull(c.r, header[:8])
+		length = binary.BigEndian.Uint64(header[:8])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return b0, payload
+}
+
+// expectClose reads a close frame and checks its code
+func (c *wsTestClient) expectClose(code int) {
+	c.t.Helper()
+	b0, payload := c.readFrame()
+	if b0 != 0x80|CloseMessage || len(payload) < 2 {
+		c.t.Fatalf("expected close frame, got %#x %q", b0, payload)
+	}
+	if got := int(binary.BigEndian.Uint16(payload)); got != code {
+		c.t.Fatalf("expected close code %d, got %d", code, got)
+	}
+}
+
+// echoServer echoes messages and reports the error that ended the loop
+func echoServer(t *testing.T) (*httptest.Server, chan error) {
+	done := make(chan error, 1)
+	app := New()
+	app.WebSocket("/ws", func(conn *WebSocketConnection) {
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				done <- err
+				return
+			}
+			conn.WriteMessage(messageType, data)
+		}
+	})
+	server := httptest.NewServer(app)
+	t.Cleanup(server.Close)
+	return server, done
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	server, _ := echoServer(t)
+
+	client := dialWebSocket(t, server, "/ws", nil)
+	if client.resp.StatusCode != 101 {
+		t.Fatalf("expected 101, got %d", client.resp.StatusCode)
+	}
+	if accept := client.resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected accept key %q", accept)
+	}
+
+	resp, err := http.Get(server.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for plain GET, got %d", resp.StatusCode)
+	}
+}
+
+func TestWebSocketRFCFrameBytes(t *testing.T) {
+	server, _ := echoServer(t)
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	client := &wsTestClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	// RFC 6455 section 1.3 opening handshake
+	io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: server.example.com\r\nUpgrade: websocket\r\n"+
+		"Connection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	var head []string
+	for {
+		line, err := client.r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line == "\r\n" {
+			break
+		}
+		head = append(head, line)
+	}
+	if len(head) == 0 || head[0] != "HTTP/1.1 101 Switching Protocols\r\n" {
+		t.Fatalf("unexpected status line %q", head)
+	}
+	accepted := false
+	for _, line := range head[1:] {
+		name, value, _ := strings.Cut(strings.TrimSuffix(line, "\r\n"), ": ")
+		accepted = accepted || strings.EqualFold(name, "Sec-WebSocket-Accept") && value == "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="
+	}
+	if !accepted {
+		t.Errorf("expected the RFC accept key, got %q", head)
+	}
+
+	// RFC 6455 section 5.7 examples, written and read byte for byte
+	tests := []struct {
+		name     string
+		request  []byte
+		expected []byte
+	}{
+		{
+			"masked text",
+			[]byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58},
+			[]byte{0x81, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f},
+		},
+		{
+			"masked ping",
+			[]byte{0x89, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58},
+			[]byte{0x8a, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f},
+		},
+		{
+			"fragmented text",
+			[]byte{0x01, 0x83, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x80, 0x82, 0x37, 0xfa, 0x21, 0x3d, 0x5b, 0x95},
+			[]byte{0x81, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f},
+		},
+	}
+	for _, tt := range tests {
+		if _, err := client.conn.Write(tt.request); err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, len(tt.expected))
+		if _, err := io.ReadFull(client.r, got); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if string(got) != string(tt.expected) {
+			t.Errorf("%s: expected % x, got % x", tt.name, tt.expected, got)
+		}
+	}
+
+	// 256 bytes of binary data use the 16-bit extended length
+	client.writeFrame(0x80|BinaryMessage, make([]byte, 256))
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(client.r, header); err != nil {
+		t.Fatal(err)
+	}
+	if string(header) != string([]byte{0x82, 0x7e, 0x01, 0x00}) {
+		t.Errorf("expected 256-byte binary header, got % x", header)
+	}
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	server, _ := echoServer(t)
+	client := dialWebSocket(t, server, "/ws", nil)
+
+	client.writeFrame(0x80|TextMessage, []byte("hello"))
+	if b0, payload := client.readFrame(); b0 != 0x80|TextMessage || string(payload) != "hello" {
+		t.Errorf("unexpected text echo %#x %q", b0, payload)
+	}
+
+	binaryData := make([]byte, 70000)
+	for i := range binaryData {
+		binaryData[i] = byte(i)
+	}
+	client.writeFrame(0x80|BinaryMessage, binaryData)
+	if b0, payload := client.readFrame(); b0 != 0x80|BinaryMessage || string(payload) != string(binaryData) {
+		t.Errorf("unexpected binary echo %#x (%d bytes)", b0, len(payload))
+	}
+}
+
+func TestWebSocketFragmentsAndPing(t *testing.T) {
+	server, _ := echoServer(t)
+	client := dialWebSocket(t, server, "/ws", nil)
+
+	// Control frames may arrive between fragments
+	client.writeFrame(TextMessage, []byte("Hel"))
+	client.writeFrame(0x80|PingMessage, []byte("ping"))
+	client.writeFrame(continuationFrame, []byte("lo, "))
+	client.writeFrame(0x80|continuationFrame, []byte("world"))
+
+	if b0, payload := client.readFrame(); b0 != 0x80|PongMessage || string(payload) != "ping" {
+		t.Errorf("expected pong, got %#x %q", b0, payload)
+	}
+	if b0, payload := client.readFrame(); b0 != 0x80|TextMessage || string(payload) != "Hello, world" {
+		t.Errorf("expected reassembled message, got %#x %q", b0, payload)
+	}
+}
+
+func TestWebSocketCloseHandshake(t *testing.T) {
+	server, done := echoServer(t)
+	client := dialWebSocket(t, server, "/ws", nil)
+
+	client.writeFrame(0x80|CloseMessage, closePayload(CloseGoingAway, "bye"))
+	client.expectClose(CloseGoingAway)
+
+	var closeErr *CloseError
+	if err := <-done; !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway || closeErr.Text != "bye" {
+		t.Errorf("expected close error 1001, got %v", err)
+	}
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	tests := map[string]struct {
+		send func(*wsTestClient)
+		code int
+	}{
+		"unmasked":            {func(c *wsTestClient) { c.writeRawFrame(0x80|TextMessage, []byte("hi"), false) }, CloseProtocolError},
+		"reserved bits":       {func(c *wsTestClient) { c.writeFrame(0xC0|TextMessage, []byte("hi")) }, CloseProtocolError},
+		"unknown opcode":      {func(c *wsTestClient) { c.writeFrame(0x83, nil) }, CloseProtocolError},
+		"orphan continuation": {func(c *wsTestClient) { c.writeFrame(0x80, []byte("x")) }, CloseProtocolError},
+		"fragmented ping":     {func(c *wsTestClient) { c.writeFrame(PingMessage, nil) }, CloseProtocolError},
+		"invalid UTF-8":       {func(c *wsTestClient) { c.writeFrame(0x80|TextMessage, []byte{0xff, 0xfe}) }, CloseInvalidPayload},
+		"invalid close code":  {func(c *wsTestClient) { c.writeFrame(0x80|CloseMessage, closePayload(1004, "")) }, CloseProtocolError},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, done := echoServer(t)
+			client := dialWebSocket(t, server, "/ws", nil)
+			test.send(client)
+			client.expectClose(test.code)
+			if err := <-done; err == nil {
+				t.Error("expected handler to see an error")
+			}
+		})
+	}
+}
+
+func TestWebSocketKeepsMiddlewareHeaders(t *testing.T) {
+	app := New()
+	app.Use(func(c *Context) error {
+		c.SetCookie(&http.Cookie{Name: "ws", Value: "1"})
+		return c.Next()
+	})
+	app.WebSocket("/ws", func(conn *WebSocketConnection) {
+		conn.Send("hi")
+	})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	client := dialWebSocket(t, server, "/ws", nil)
+	if !strings.Contains(client.resp.Header.Get("Set-Cookie"), "ws=1") {
+		t.Errorf("expected cookie on the handshake, got %v", client.resp.Header)
+	}
+	if _, payload := client.readFrame(); string(payload) != "hi" {
+		t.Errorf("unexpected message %q", payload)
+	}
+	// Returning from the handler closes normally
+	client.expectClose(CloseNormalClosure)
+}
+
+func TestWebSocketHandshakeValidation(t *testing.T) {
+	app := New()
+	config := NewWebSocketConfig()
+	config.Subprotocols = []string{"v2.chat", "v1.chat"}
+	config.ResponseHeader = func(c *Context, header http.Header) error {
+		header.Set("X-Server", "forge")
+		return nil
+	}
+	app.WebSocketWithConfig("/ws", config, func(conn *WebSocketConnection) {
+		conn.Send(conn.Subprotocol())
+	})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	client := dialWebSocket(t, server, "/ws", http.Header{
+		"Sec-Websocket-Protocol": {"v1.chat, v2.chat"},
+	})
+	if client.resp.StatusCode != 101 || client.resp.Header.Get("Sec-WebSocket-Protocol") != "v2.chat" {
+		t.Fatalf("expected v2.chat to be negotiated, got %d %v", client.resp.StatusCode, client.resp.Header)
+	}
+	if client.resp.Header.Get("X-Server") != "forge" {
+		t.Error("expected the response header hook to run")
+	}
+	if _, payload := client.readFrame(); string(payload) != "v2.chat" {
+		t.Errorf("unexpected subprotocol %q", payload)
+	}
+
+	client = dialWebSocket(t, server, "/ws", http.Header{"Sec-Websocket-Protocol": {"mqtt"}})
+	if client.resp.StatusCode != 101 || client.resp.Header.Get("Sec-WebSocket-Protocol") != "" {
+		t.Errorf("expected no subprotocol, got %v", client.resp.Header)
+	}
+
+	client = dialWebSocket(t, server, "/ws", http.Header{"Sec-Websocket-Version": {"8"}})
+	if client.resp.StatusCode != 426 || client.resp.Header.Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("expected 426 with supported version, got %d", client.resp.StatusCode)
+	}
+
+	client = dialWebSocket(t, server, "/ws", http.Header{"Sec-Websocket-Key": {"short"}})
+	if client.resp.StatusCode != 400 {
+		t.Errorf("expected 400 for invalid key, got %d", client.resp.StatusCode)
+	}
+}
+
+func TestWebSocketOriginCheck(t *testing.T) {
+	app := New()
+	app.WebSocket("/same", func(conn *WebSocketConnection) {})
+	config := NewWebSocketConfig()
+	config.CheckOrigin = func(r *http.Request) bool {
+		return r.Header.Get("Origin") == "https://app.example.com"
+	}
+	app.WebSocketWithConfig("/custom", config, func(conn *WebSocketConnection) {})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	tests := []struct {
+		path, origin string
+		status       int
+	}{
+		{"/same", "", 101},
+		{"/same", server.URL, 101},
+		{"/same", "https://evil.example.com", 403},
+		{"/custom", "https://app.example.com", 101},
+		{"/custom", server.URL, 403},
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		if test.origin != "" {
+			header.Set("Origin", test.origin)
+		}
+		client := dialWebSocket(t, server, test.path, header)
+		if client.resp.StatusCode != test.status {
+			t.Errorf("%s from %q: expected %d, got %d", test.path, test.origin, test.status, client.resp.StatusCode)
+		}
+	}
+}
+
+func TestWebSocketAuthBeforeUpgrade(t *testing.T) {
+	jwtConfig := NewJWTConfig("secret")
+	token, _ := jwtConfig.GenerateToken(map[string]interface{}{"sub": "42"})
+	auth := NewJWTAuthConfig(jwtConfig)
+	auth.TokenLookup = "query:token"
+
+	app := New()
+	config := NewWebSocketConfig()
+	config.Middleware = []MiddlewareFunc{JWTAuthWithConfig(auth)}
+	app.WebSocketWithConfig("/ws", config, func(conn *WebSocketConnection) {
+		conn.Send("welcome")
+	})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	client := dialWebSocket(t, server, "/ws", nil)
+	if client.resp.StatusCode != 401 {
+		t.Errorf("expected 401 without token, got %d", client.resp.StatusCode)
+	}
+
+	client = dialWebSocket(t, server, "/ws?token="+token, nil)
+	if client.resp.StatusCode != 101 {
+		t.Fatalf("expected 101 with token, got %d", client.resp.StatusCode)
+	}
+	if _, payload := client.readFrame(); string(payload) != "welcome" {
+		t.Errorf("unexpected message %q", payload)
+	}
+}
+
+func TestWebSocketReadLimit(t *testing.T) {
+	done := make(chan error, 1)
+	app := New()
+	config := NewWebSocketConfig()
+	config.ReadLimit = 8
+	app.WebSocketWithConfig("/ws", config, func(conn *WebSocketConnection) {
+		_, _, err := conn.ReadMessage()
+		done <- err
+	})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	client := dialWebSocket(t, server, "/ws", nil)
+	// The limit applies to the reassembled message, not single frames
+	client.writeFrame(TextMessage, []byte("12345"))
+	client.writeFrame(0x80|continuationFrame, []byte("6789"))
+	client.expectClose(CloseMessageTooBig)
+
+	var closeErr *CloseError
+	if err := <-done; !errors.As(err, &closeErr) || closeErr.Code != CloseMessageTooBig {
+		t.Errorf("expected message too big, got %v", err)
+	}
+}
+
+func TestWebSocketPingAndPongTimeout(t *testing.T) {
+	done := make(chan error, 1)
+	app := New()
+	config := NewWebSocketConfig()
+	config.PingInterval = 20 * time.Millisecond
+	config.PongTimeout = 150 * time.Millisecond
+	app.WebSocketWithConfig("/ws", config, func(conn *WebSocketConnection) {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				done <- err
+				return
+			}
+		}
+	})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	client := dialWebSocket(t, server, "/ws", nil)
+
+	// Answering pings keeps the connection alive past the timeout
+	deadline := time.Now().Add(300 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		b0, payload := client.readFrame()
+		if b0 != 0x80|PingMessage {
+			t.Fatalf("expected ping, got %#x", b0)
+		}
+		client.writeFrame(0x80|PongMessage, payload)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("connection closed while answering pings: %v", err)
+	default:
+	}
+
+	// A silent peer is dropped
+	var closeErr *CloseError
+	select {
+	case err := <-done:
+		if !errors.As(err, &closeErr) || closeErr.Code != CloseAbnormalClosure {
+			t.Errorf("expected abnormal closure, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the silent connection to be closed")
+	}
+}
+
+func TestWebSocketConcurrentWrites(t *testing.T) {
+	const writers, messages = 8, 50
+	app := New()
+	app.WebSocket("/ws", func(conn *WebSocketConnection) {
+		finished := make(chan struct{})
+		for i := 0; i < writers; i++ {
+			go func() {
+				for j := 0; j < messages; j++ {
+					conn.WriteMessage(BinaryMessage, make([]byte, 300))
+				}
+				finished <- struct{}{}
+			}()
+		}
+		for i := 0; i < writers; i++ {
+			<-finished
+		}
+	})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	client := dialWebSocket(t, server, "/ws", nil)
+	for i := 0; i < writers*messages; i++ {
+		if b0, payload := client.readFrame(); b0 != 0x80|BinaryMessage || len(payload) != 300 {
+			t.Fatalf("frame %d corrupted: %#x with %d bytes", i, b0, len(payload))
+		}
+	}
+	client.expectClose(CloseNormalClosure)
+}
+
+func TestWebSocketBroadcasterRemovesClosedConnections(t *testing.T) {
+	broadcaster := WebSocketBroadcast()
+	joined := make(chan struct{}, 2)
+	app := New()
+	config := NewWebSocketConfig()
+	config.SendQueueSize = 256
+	app.WebSocketWithConfig("/ws", config, func(conn *WebSocketConnection) {
+		broadcaster.AddConnection(conn)
+		joined <- struct{}{}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	first := dialWebSocket(t, server, "/ws", nil)
+	second := dialWebSocket(t, server, "/ws", nil)
+	<-joined
+	<-joined
+
+	for i := 0; i < 100; i++ {
+		broadcaster.Broadcast("message")
+	}
+	for i := 0; i < 100; i++ {
+		if _, payload := first.readFrame(); string(payload) != "message" {
+			t.Fatalf("unexpected broadcast %q", payload)
+		}
+	}
+
+	second.conn.Close()
+	for deadline := time.Now().Add(2 * time.Second); broadcaster.Count() != 1; {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected dead connection to be removed, have %d", broadcaster.Count())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}